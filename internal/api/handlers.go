@@ -1,29 +1,49 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"chatmock/internal/budgets"
 	"chatmock/internal/chat"
+	"chatmock/internal/faults"
+	"chatmock/internal/recorder"
 	"chatmock/internal/remote"
 	"chatmock/internal/rules"
+	"chatmock/internal/tokens"
 )
 
 type Handlers struct {
 	rules         *rules.Store
 	remoteManager *remote.Manager
 	remoteClient  *remote.Client
+	faults        *faults.Store
+	recorder      *recorder.Recorder
+	budgets       *budgets.Store
+	tokens        tokens.Counter
 }
 
-func NewHandlers(store *rules.Store, manager *remote.Manager) *Handlers {
+func NewHandlers(store *rules.Store, manager *remote.Manager, faultStore *faults.Store, rec *recorder.Recorder, budgetStore *budgets.Store, counter tokens.Counter) *Handlers {
 	if manager == nil {
 		manager = remote.NewManager(nil)
 	}
-	return &Handlers{rules: store, remoteManager: manager, remoteClient: remote.NewClient()}
+	if faultStore == nil {
+		faultStore = faults.NewStore(nil)
+	}
+	if rec == nil {
+		rec = recorder.New()
+	}
+	if budgetStore == nil {
+		budgetStore = budgets.NewStore(nil)
+	}
+	if counter == nil {
+		counter = tokens.FromEnv()
+	}
+	return &Handlers{rules: store, remoteManager: manager, remoteClient: remote.NewClient(), faults: faultStore, recorder: rec, budgets: budgetStore, tokens: counter}
 }
 
 func (h *Handlers) Health(w http.ResponseWriter, _ *http.Request) {
@@ -77,12 +97,112 @@ func (h *Handlers) Providers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handlers) Faults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"faults": h.faults.All()})
+	case http.MethodPut:
+		var payload struct {
+			Faults []faults.Fault `json:"faults"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+			return
+		}
+		h.faults.Set(payload.Faults)
+		writeJSON(w, http.StatusOK, map[string]any{"faults": h.faults.All()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handlers) ProvidersHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"health": h.remoteManager.Health()})
+}
+
+func (h *Handlers) Budgets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"budgets": h.budgets.All()})
+	case http.MethodPut:
+		var payload struct {
+			Budgets []budgets.Budget `json:"budgets"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+			return
+		}
+		h.budgets.Set(payload.Budgets)
+		writeJSON(w, http.StatusOK, map[string]any{"budgets": h.budgets.All()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handlers) BudgetsUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": h.budgets.Usage()})
+}
+
+func (h *Handlers) Recordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var payload struct {
+		Mode string `json:"mode"`
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
+		return
+	}
+	if strings.TrimSpace(payload.Path) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "path is required"})
+		return
+	}
+	var err error
+	switch recorder.Mode(payload.Mode) {
+	case recorder.ModeRecord:
+		err = h.recorder.Start(payload.Path)
+	case recorder.ModeReplay, recorder.ModePassthroughOnMiss:
+		err = h.recorder.SetMode(recorder.Mode(payload.Mode), payload.Path)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mode must be record, replay, or passthrough_on_miss"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"mode": string(h.recorder.Mode())})
+}
+
+func (h *Handlers) RecordingsStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.recorder.Stop(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"mode": string(h.recorder.Mode())})
+}
+
 func (h *Handlers) Models(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	writeJSON(w, http.StatusOK, h.buildModelsResponse())
+	writeJSON(w, http.StatusOK, h.buildModelsResponse(r.Context()))
 }
 
 func (h *Handlers) Responses(w http.ResponseWriter, r *http.Request) {
@@ -95,7 +215,11 @@ func (h *Handlers) Responses(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
 		return
 	}
-	ccReq := chat.CompletionRequest{Model: req.Model, Messages: []chat.Message{{Role: "user", Content: flattenInput(req.Input)}}}
+	ccReq := chat.CompletionRequest{Model: req.Model, Messages: []chat.Message{{Role: "user", Content: flattenInput(req.Input)}}, Stream: req.Stream}
+	if ccReq.Stream {
+		h.streamResponses(w, r, ccReq)
+		return
+	}
 	ccResp, status, err := h.runCompletion(r, ccReq)
 	if err != nil {
 		writeJSON(w, status, map[string]string{"error": err.Error()})
@@ -105,7 +229,7 @@ func (h *Handlers) Responses(w http.ResponseWriter, r *http.Request) {
 	if len(ccResp.Choices) > 0 {
 		output = ccResp.Choices[0].Message.Content
 	}
-	writeJSON(w, http.StatusOK, chat.ResponsesResponse{ID: "resp-mock", Object: "response", CreatedAt: time.Now().Unix(), Model: ccResp.Model, OutputText: output})
+	writeJSON(w, status, chat.ResponsesResponse{ID: "resp-mock", Object: "response", CreatedAt: time.Now().Unix(), Model: ccResp.Model, OutputText: output})
 }
 
 func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
@@ -118,12 +242,16 @@ func (h *Handlers) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
 		return
 	}
+	if req.Stream {
+		h.streamChatCompletions(w, r, req)
+		return
+	}
 	resp, status, err := h.runCompletion(r, req)
 	if err != nil {
 		writeJSON(w, status, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, resp)
+	writeJSON(w, status, resp)
 }
 
 func (h *Handlers) Completions(w http.ResponseWriter, r *http.Request) {
@@ -134,11 +262,16 @@ func (h *Handlers) Completions(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Model  string `json:"model"`
 		Prompt string `json:"prompt"`
+		Stream bool   `json:"stream"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
 		return
 	}
+	if req.Stream {
+		h.streamChatCompletions(w, r, chat.CompletionRequest{Model: req.Model, Messages: []chat.Message{{Role: "user", Content: req.Prompt}}, Stream: true})
+		return
+	}
 	ccResp, status, err := h.runCompletion(r, chat.CompletionRequest{Model: req.Model, Messages: []chat.Message{{Role: "user", Content: req.Prompt}}})
 	if err != nil {
 		writeJSON(w, status, map[string]string{"error": err.Error()})
@@ -148,7 +281,7 @@ func (h *Handlers) Completions(w http.ResponseWriter, r *http.Request) {
 	if len(ccResp.Choices) > 0 {
 		text = ccResp.Choices[0].Message.Content
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
+	writeJSON(w, status, map[string]any{
 		"id":      ccResp.ID,
 		"object":  "text_completion",
 		"created": ccResp.Created,
@@ -171,6 +304,10 @@ func (h *Handlers) OllamaChat(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON payload"})
 		return
 	}
+	if req.Stream {
+		h.streamOllamaChat(w, r, chat.CompletionRequest{Model: req.Model, Messages: req.Messages, Stream: true})
+		return
+	}
 	resp, status, err := h.runCompletion(r, chat.CompletionRequest{Model: req.Model, Messages: req.Messages})
 	if err != nil {
 		writeJSON(w, status, map[string]string{"error": err.Error()})
@@ -180,18 +317,13 @@ func (h *Handlers) OllamaChat(w http.ResponseWriter, r *http.Request) {
 	if len(resp.Choices) > 0 {
 		content = resp.Choices[0].Message.Content
 	}
-	if req.Stream {
-		w.Header().Set("Content-Type", "application/x-ndjson")
-		w.WriteHeader(http.StatusOK)
-		_, _ = io.WriteString(w, fmt.Sprintf("{\"model\":%q,\"message\":{\"role\":\"assistant\",\"content\":%q},\"done\":false}\n", chooseModel(resp.Model), content))
-		_, _ = io.WriteString(w, fmt.Sprintf("{\"model\":%q,\"done\":true}\n", chooseModel(resp.Model)))
-		return
-	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"model":      chooseModel(resp.Model),
-		"created_at": time.Now().UTC().Format(time.RFC3339),
-		"message":    map[string]any{"role": "assistant", "content": content},
-		"done":       true,
+	writeJSON(w, status, map[string]any{
+		"model":             chooseModel(resp.Model),
+		"created_at":        time.Now().UTC().Format(time.RFC3339),
+		"message":           map[string]any{"role": "assistant", "content": content},
+		"done":              true,
+		"prompt_eval_count": resp.Usage.PromptTokens,
+		"eval_count":        resp.Usage.CompletionTokens,
 	})
 }
 
@@ -201,7 +333,7 @@ func (h *Handlers) OllamaTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	models := make([]map[string]any, 0)
-	for _, m := range h.buildModelsResponse().Data {
+	for _, m := range h.buildModelsResponse(r.Context()).Data {
 		models = append(models, map[string]any{"name": m.ID, "model": m.ID})
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"models": models})
@@ -231,39 +363,127 @@ func (h *Handlers) runCompletion(r *http.Request, req chat.CompletionRequest) (c
 	if len(req.Messages) == 0 {
 		return chat.CompletionResponse{}, http.StatusBadRequest, fmt.Errorf("messages must not be empty")
 	}
-	if provider, model, ok := h.remoteManager.Match(req.Model); ok {
-		respBody, status, err := h.remoteClient.ChatCompletions(r.Context(), provider, req, model)
-		if err != nil {
-			return chat.CompletionResponse{}, http.StatusBadGateway, fmt.Errorf("remote request failed: %w", err)
+	if cassette, ok := h.recorder.Lookup(req); ok {
+		var out chat.CompletionResponse
+		if jsonErr := json.Unmarshal(cassette.Body, &out); jsonErr != nil {
+			return chat.CompletionResponse{}, http.StatusBadGateway, fmt.Errorf("invalid cassette response")
 		}
-		if status >= 400 {
-			return chat.CompletionResponse{}, status, fmt.Errorf("remote returned status %d", status)
+		return out, cassette.Status, nil
+	}
+	if h.recorder.Mode() == recorder.ModeReplay {
+		return chat.CompletionResponse{}, http.StatusNotFound, fmt.Errorf("no cassette entry recorded for this request")
+	}
+
+	start := time.Now()
+	respBody, status, matched, providerName, err := h.remoteManager.DispatchChatCompletion(r.Context(), h.remoteClient, req)
+	if matched {
+		h.recorder.Record(req, providerName, status, respBody, time.Since(start))
+		if err != nil {
+			if status == 0 {
+				return chat.CompletionResponse{}, http.StatusBadGateway, fmt.Errorf("remote request failed: %w", err)
+			}
+			return chat.CompletionResponse{}, status, err
 		}
 		var out chat.CompletionResponse
-		if err := json.Unmarshal(respBody, &out); err != nil {
+		if jsonErr := json.Unmarshal(respBody, &out); jsonErr != nil {
 			return chat.CompletionResponse{}, http.StatusBadGateway, fmt.Errorf("invalid remote response")
 		}
+		h.observeUsage(req, out)
+		h.fillUsage(req, &out)
 		return out, http.StatusOK, nil
 	}
-	last := req.Messages[len(req.Messages)-1].Content
-	reply, ok := h.rules.Match(last)
-	if !ok {
-		reply = "Mock response: I received your message and no custom rule matched."
+	reply := "Mock response: I received your message and no custom rule matched."
+	statusCode := http.StatusOK
+	finishReason := "stop"
+	var toolCalls []chat.ToolCall
+	if matched, ok := h.rules.MatchRule(toMatchContext(req)); ok {
+		reply = renderRuleText(matched, req, 0)
+		if len(matched.Response.ToolCalls) > 0 {
+			toolCalls = toChatToolCalls(matched.Response.ToolCalls)
+			finishReason = "tool_calls"
+		}
+		if matched.Response.Delay > 0 {
+			time.Sleep(time.Duration(matched.Response.Delay) * time.Millisecond)
+		}
+		if matched.Response.StatusCode != 0 {
+			statusCode = matched.Response.StatusCode
+		}
+	}
+	resp := chat.CompletionResponse{ID: "chatcmpl-mock", Object: "chat.completion", Created: time.Now().Unix(), Model: chooseModel(req.Model), Choices: []chat.Choice{{Index: 0, FinishReason: finishReason, Message: chat.Message{Role: "assistant", Content: reply, ToolCalls: toolCalls}}}}
+	resp.Usage = h.completionUsage(req, reply)
+	return resp, statusCode, nil
+}
+
+// toChatToolCalls converts a rule's provider-agnostic tool calls into the
+// chat package's OpenAI-shaped ToolCall, keeping rules decoupled from chat.
+func toChatToolCalls(calls []rules.ToolCall) []chat.ToolCall {
+	out := make([]chat.ToolCall, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, chat.ToolCall{ID: c.ID, Type: c.Type, Function: chat.ToolCallFunction{Name: c.Function.Name, Arguments: c.Function.Arguments}})
+	}
+	return out
+}
+
+// observeUsage records a remote provider's self-reported token counts
+// (e.g. Ollama's prompt_eval_count/eval_count) into h.tokens when it's a
+// PassthroughCounter, so a later identical prompt reuses the real count
+// instead of an estimate.
+func (h *Handlers) observeUsage(req chat.CompletionRequest, out chat.CompletionResponse) {
+	passthrough, ok := h.tokens.(*tokens.PassthroughCounter)
+	if !ok || (out.Usage.PromptTokens == 0 && out.Usage.CompletionTokens == 0) {
+		return
+	}
+	if out.Usage.PromptTokens != 0 {
+		passthrough.Observe(req.Model, tokens.JoinMessages(req.Messages), out.Usage.PromptTokens)
+	}
+	if out.Usage.CompletionTokens != 0 && len(out.Choices) > 0 {
+		passthrough.Observe(req.Model, out.Choices[0].Message.Content, out.Usage.CompletionTokens)
+	}
+}
+
+// fillUsage populates out.Usage from h.tokens when a remote provider
+// didn't report any (e.g. the ChatGPT backend), so prompt_tokens and
+// completion_tokens are always present regardless of provider kind.
+func (h *Handlers) fillUsage(req chat.CompletionRequest, out *chat.CompletionResponse) {
+	if out.Usage.PromptTokens != 0 || out.Usage.CompletionTokens != 0 {
+		return
+	}
+	reply := ""
+	if len(out.Choices) > 0 {
+		reply = out.Choices[0].Message.Content
 	}
-	resp := chat.CompletionResponse{ID: "chatcmpl-mock", Object: "chat.completion", Created: time.Now().Unix(), Model: chooseModel(req.Model), Choices: []chat.Choice{{Index: 0, FinishReason: "stop", Message: chat.Message{Role: "assistant", Content: reply}}}, Usage: chat.Usage{PromptTokens: estimateTokens(req.Messages), CompletionTokens: estimateTokens([]chat.Message{{Role: "assistant", Content: reply}})}}
-	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
-	return resp, http.StatusOK, nil
+	out.Usage = h.completionUsage(req, reply)
 }
 
-func (h *Handlers) buildModelsResponse() chat.ModelsResponse {
+// completionUsage estimates prompt/completion token counts for a reply
+// generated locally (a mock rule or a fallback reply) using h.tokens.
+func (h *Handlers) completionUsage(req chat.CompletionRequest, reply string) chat.Usage {
+	usage := chat.Usage{
+		PromptTokens:     tokens.CountMessages(h.tokens, req.Model, req.Messages),
+		CompletionTokens: h.tokens.Count(req.Model, reply),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// buildModelsResponse enumerates the mock model plus each configured
+// provider's real models via its adapter's Models method, falling back to
+// a prefix/* placeholder when a provider can't be queried (no Models
+// support, or a failed/empty upstream call).
+func (h *Handlers) buildModelsResponse(ctx context.Context) chat.ModelsResponse {
 	created := time.Now().Unix()
 	models := []chat.ModelInfo{{ID: "gpt-mock-1", Object: "model", Created: created, OwnedBy: "chatmock"}}
 	for _, p := range h.remoteManager.Providers() {
-		id := strings.TrimSuffix(p.ModelPrefix, "/") + "/*"
-		if strings.TrimSpace(p.ModelPrefix) == "" {
-			id = p.Name
+		providerModels, err := h.remoteClient.Models(ctx, p)
+		if err != nil || len(providerModels) == 0 {
+			id := strings.TrimSuffix(p.ModelPrefix, "/") + "/*"
+			if strings.TrimSpace(p.ModelPrefix) == "" {
+				id = p.Name
+			}
+			models = append(models, chat.ModelInfo{ID: id, Object: "model", Created: created, OwnedBy: p.Kind})
+			continue
 		}
-		models = append(models, chat.ModelInfo{ID: id, Object: "model", Created: created, OwnedBy: p.Kind})
+		models = append(models, providerModels...)
 	}
 	return chat.ModelsResponse{Object: "list", Data: models}
 }
@@ -298,20 +518,6 @@ func chooseModel(model string) string {
 	return model
 }
 
-func estimateTokens(messages []chat.Message) int {
-	total := 0
-	for _, m := range messages {
-		total += len(m.Content)
-	}
-	if total == 0 {
-		return 0
-	}
-	if total < 4 {
-		return 1
-	}
-	return total / 4
-}
-
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+
+	"chatmock/internal/chat"
+	"chatmock/internal/rules"
+)
+
+// templateData is the context exposed to a Rule's Response.Template.
+// Match1..Match9 hold the rule's regex capture groups (Match1 is the
+// first group), empty when the rule has no regex or the group didn't
+// participate in the match.
+type templateData struct {
+	Messages []chat.Message
+	Model    string
+	Index    int
+	Now      time.Time
+	Rand     float64
+
+	Match1, Match2, Match3, Match4, Match5, Match6, Match7, Match8, Match9 string
+}
+
+// renderRuleText resolves the text a matched rule should reply with. A
+// Response.Template takes precedence over the static Response.Text; if it
+// fails to parse or execute, the static text is used as a safe fallback.
+func renderRuleText(rule rules.Rule, req chat.CompletionRequest, index int) string {
+	if strings.TrimSpace(rule.Response.Template) == "" {
+		return rule.Response.Text
+	}
+	tmpl, err := template.New("rule").Parse(rule.Response.Template)
+	if err != nil {
+		return rule.Response.Text
+	}
+	data := templateData{Messages: req.Messages, Model: chooseModel(req.Model), Index: index, Now: time.Now(), Rand: rand.Float64()}
+	setCaptureGroups(&data, rule.CaptureGroups(toMatchContext(req)))
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return rule.Response.Text
+	}
+	return buf.String()
+}
+
+// setCaptureGroups fills data.Match1..Match9 from groups, a regexp
+// FindStringSubmatch result whose index 0 is the full match and whose
+// remaining entries are the capture groups.
+func setCaptureGroups(data *templateData, groups []string) {
+	fields := []*string{&data.Match1, &data.Match2, &data.Match3, &data.Match4, &data.Match5, &data.Match6, &data.Match7, &data.Match8, &data.Match9}
+	for i, field := range fields {
+		if groupIndex := i + 1; groupIndex < len(groups) {
+			*field = groups[groupIndex]
+		}
+	}
+}
+
+// toMatchContext converts a chat.CompletionRequest into the generic
+// rules.MatchContext the rule engine evaluates against.
+func toMatchContext(req chat.CompletionRequest) rules.MatchContext {
+	messages := make([]rules.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, rules.Message{Role: m.Role, Content: m.Content})
+	}
+	return rules.MatchContext{Model: req.Model, Messages: messages, Body: requestBody(req)}
+}
+
+// requestBody round-trips req through JSON so JSONPath conditions can be
+// evaluated against the request the way a client actually sent it.
+func requestBody(req chat.CompletionRequest) map[string]any {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil
+	}
+	return body
+}
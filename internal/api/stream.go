@@ -0,0 +1,286 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmock/internal/chat"
+	"chatmock/internal/recorder"
+	"chatmock/internal/tokens"
+)
+
+// streamChunks splits text into small, token-sized pieces for mocked
+// streaming replies. Splitting on spaces keeps whole words together while
+// still producing multiple chunks for anything longer than one word.
+func streamChunks(text string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	chunks := make([]string, len(words))
+	for i, w := range words {
+		if i > 0 {
+			w = " " + w
+		}
+		chunks[i] = w
+	}
+	return chunks
+}
+
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+func writeSSEDone(w http.ResponseWriter, flusher http.Flusher) {
+	_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// resolveMockChunks evaluates the rule engine for req and returns the
+// chunks to stream back, any tool calls the matched rule configured, and
+// the configured inter-chunk delay. A rule's explicit Response.Stream
+// chunks take precedence over splitting the rule's rendered reply text
+// (its Template when set, else Response.Text) into words.
+func (h *Handlers) resolveMockChunks(req chat.CompletionRequest) (chunks []string, toolCalls []chat.ToolCall, delayMS int) {
+	matched, ok := h.rules.MatchRule(toMatchContext(req))
+	if !ok {
+		return streamChunks("Mock response: I received your message and no custom rule matched."), nil, 0
+	}
+	toolCalls = toChatToolCalls(matched.Response.ToolCalls)
+	if len(matched.Response.Stream) > 0 {
+		return matched.Response.Stream, toolCalls, matched.Response.Delay
+	}
+	return streamChunks(renderRuleText(matched, req, 0)), toolCalls, matched.Response.Delay
+}
+
+// resolveStreamedCompletion is the streaming counterpart to runCompletion:
+// a recorded cassette is replayed by re-chunking its saved content over
+// onDelta, a live dispatch forwards every provider delta to onDelta as it
+// arrives while accumulating the reply so the complete exchange can be
+// recorded afterwards, and a model with no matching provider falls back to
+// the mock rule engine. Replay mode never falls back on a cassette miss,
+// matching runCompletion's behavior. The caller's onDelta is responsible
+// for writing each delta in its own transport's wire format; this method
+// only decides what content to send and when to record it.
+func (h *Handlers) resolveStreamedCompletion(r *http.Request, req chat.CompletionRequest, onDelta func(delta chat.ChunkDelta) error) (reply string, toolCalls []chat.ToolCall, finishReason string, status int, err error) {
+	ctx := r.Context()
+	if cassette, ok := h.recorder.Lookup(req); ok {
+		var out chat.CompletionResponse
+		if jsonErr := json.Unmarshal(cassette.Body, &out); jsonErr != nil {
+			return "", nil, "", http.StatusBadGateway, fmt.Errorf("invalid cassette response")
+		}
+		finishReason = "stop"
+		if len(out.Choices) > 0 {
+			reply = out.Choices[0].Message.Content
+			toolCalls = out.Choices[0].Message.ToolCalls
+			finishReason = out.Choices[0].FinishReason
+		}
+		for _, chunk := range streamChunks(reply) {
+			if err := onDelta(chat.ChunkDelta{Content: chunk}); err != nil {
+				return reply, toolCalls, finishReason, cassette.Status, err
+			}
+		}
+		if len(toolCalls) > 0 {
+			if err := onDelta(chat.ChunkDelta{ToolCalls: toolCalls}); err != nil {
+				return reply, toolCalls, finishReason, cassette.Status, err
+			}
+		}
+		return reply, toolCalls, finishReason, cassette.Status, nil
+	}
+	if h.recorder.Mode() == recorder.ModeReplay {
+		return "", nil, "", http.StatusNotFound, fmt.Errorf("no cassette entry recorded for this request")
+	}
+
+	if candidates := h.remoteManager.Candidates(req.Model); len(candidates) > 0 {
+		var builder strings.Builder
+		var calls []chat.ToolCall
+		start := time.Now()
+		dispatchStatus, _, providerName, dispatchErr := h.remoteManager.DispatchStreamChatCompletion(ctx, h.remoteClient, req, func(delta chat.ChunkDelta) error {
+			builder.WriteString(delta.Content)
+			calls = append(calls, delta.ToolCalls...)
+			return onDelta(delta)
+		})
+		finishReason = "stop"
+		if len(calls) > 0 {
+			finishReason = "tool_calls"
+		}
+		if dispatchErr == nil {
+			body, marshalErr := json.Marshal(chat.CompletionResponse{
+				ID: "chatcmpl-" + providerName, Object: "chat.completion", Created: time.Now().Unix(), Model: req.Model,
+				Choices: []chat.Choice{{Index: 0, FinishReason: finishReason, Message: chat.Message{Role: "assistant", Content: builder.String(), ToolCalls: calls}}},
+			})
+			if marshalErr == nil {
+				h.recorder.Record(req, providerName, dispatchStatus, body, time.Since(start))
+			}
+		}
+		return builder.String(), calls, finishReason, dispatchStatus, dispatchErr
+	}
+
+	chunks, mockToolCalls, delay := h.resolveMockChunks(req)
+	var builder strings.Builder
+	for _, chunk := range chunks {
+		if ctx.Err() != nil {
+			return builder.String(), mockToolCalls, "stop", http.StatusOK, ctx.Err()
+		}
+		builder.WriteString(chunk)
+		if err := onDelta(chat.ChunkDelta{Content: chunk}); err != nil {
+			return builder.String(), mockToolCalls, "stop", http.StatusOK, err
+		}
+		if delay > 0 {
+			time.Sleep(time.Duration(delay) * time.Millisecond)
+		}
+	}
+	finishReason = "stop"
+	if len(mockToolCalls) > 0 {
+		if err := onDelta(chat.ChunkDelta{ToolCalls: mockToolCalls}); err != nil {
+			return builder.String(), mockToolCalls, finishReason, http.StatusOK, err
+		}
+		finishReason = "tool_calls"
+	}
+	return builder.String(), mockToolCalls, finishReason, http.StatusOK, nil
+}
+
+// streamChatCompletions serves a /v1/chat/completions (and /v1/completions)
+// style SSE stream, either from a matched rule's reply or by opening an
+// upstream streaming connection through the configured provider.
+func (h *Handlers) streamChatCompletions(w http.ResponseWriter, r *http.Request, req chat.CompletionRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "messages must not be empty"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	id := "chatcmpl-mock"
+	model := chooseModel(req.Model)
+	created := time.Now().Unix()
+	ctx := r.Context()
+
+	emit := func(delta chat.ChunkDelta) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return writeSSE(w, flusher, chat.CompletionChunk{
+			ID: id, Object: "chat.completion.chunk", Created: created, Model: model,
+			Choices: []chat.ChunkChoice{{Index: 0, Delta: delta}},
+		})
+	}
+
+	_ = writeSSE(w, flusher, chat.CompletionChunk{
+		ID: id, Object: "chat.completion.chunk", Created: created, Model: model,
+		Choices: []chat.ChunkChoice{{Index: 0, Delta: chat.ChunkDelta{Role: "assistant"}}},
+	})
+	reply, _, finishReason, status, err := h.resolveStreamedCompletion(r, req, emit)
+	if err != nil && ctx.Err() == nil {
+		_ = writeSSE(w, flusher, map[string]string{"error": fmt.Sprintf("stream failed (status %d): %v", status, err)})
+	}
+	usage := &chat.Usage{PromptTokens: tokens.CountMessages(h.tokens, req.Model, req.Messages), CompletionTokens: h.tokens.Count(req.Model, reply)}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	_ = writeSSE(w, flusher, chat.CompletionChunk{
+		ID: id, Object: "chat.completion.chunk", Created: created, Model: model,
+		Choices: []chat.ChunkChoice{{Index: 0, Delta: chat.ChunkDelta{}, FinishReason: &finishReason}},
+		Usage:   usage,
+	})
+	writeSSEDone(w, flusher)
+}
+
+// streamResponses serves a /v1/responses SSE stream using the
+// response.output_text.delta / response.completed event pair.
+func (h *Handlers) streamResponses(w http.ResponseWriter, r *http.Request, req chat.CompletionRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	id := "resp-mock"
+	ctx := r.Context()
+
+	// /v1/responses has no tool-call shape in its response schema yet
+	// (chat.ResponsesResponse only carries OutputText), so tool-call deltas
+	// are dropped here — same limitation as the non-streaming Responses
+	// handler.
+	emit := func(delta chat.ChunkDelta) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if delta.Content == "" {
+			return nil
+		}
+		return writeSSE(w, flusher, chat.ResponseStreamEvent{Type: "response.output_text.delta", ResponseID: id, Delta: delta.Content})
+	}
+
+	reply, _, _, status, err := h.resolveStreamedCompletion(r, req, emit)
+	if err != nil && ctx.Err() == nil {
+		_ = writeSSE(w, flusher, map[string]string{"error": fmt.Sprintf("stream failed (status %d): %v", status, err)})
+	}
+	_ = writeSSE(w, flusher, chat.ResponseStreamEvent{Type: "response.completed", ResponseID: id, OutputText: reply})
+}
+
+// streamOllamaChat serves /api/chat's line-delimited JSON streaming format.
+func (h *Handlers) streamOllamaChat(w http.ResponseWriter, r *http.Request, req chat.CompletionRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	ctx := r.Context()
+	model := chooseModel(req.Model)
+
+	writeLine := func(v any) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		_, _ = w.Write(append(data, '\n'))
+		flusher.Flush()
+	}
+
+	emit := func(delta chat.ChunkDelta) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		message := map[string]any{"role": "assistant", "content": delta.Content}
+		if len(delta.ToolCalls) > 0 {
+			message["tool_calls"] = delta.ToolCalls
+		}
+		writeLine(map[string]any{"model": model, "message": message, "done": false})
+		return nil
+	}
+
+	reply, _, _, _, _ := h.resolveStreamedCompletion(r, req, emit)
+	writeLine(map[string]any{
+		"model":             model,
+		"done":              true,
+		"prompt_eval_count": tokens.CountMessages(h.tokens, req.Model, req.Messages),
+		"eval_count":        h.tokens.Count(req.Model, reply),
+	})
+}
@@ -5,23 +5,48 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"chatmock/internal/api"
+	"chatmock/internal/budgets"
+	"chatmock/internal/faults"
+	"chatmock/internal/recorder"
 	"chatmock/internal/remote"
 	"chatmock/internal/rules"
+	"chatmock/internal/storage"
+	"chatmock/internal/tokens"
 )
 
+// storagePollInterval is how often the storage watcher checks the on-disk
+// providers.json/rules.json for out-of-band edits.
+const storagePollInterval = 2 * time.Second
+
 type Server struct {
-	handlers *api.Handlers
+	handlers    *api.Handlers
+	faultStore  *faults.Store
+	budgetStore *budgets.Store
+	manager     *remote.Manager
+	tokens      tokens.Counter
 }
 
 func NewServer() *Server {
 	store := rules.NewStore([]rules.Rule{
-		{Contains: "hello", Reply: "Hi! This is a mocked assistant response."},
-		{Contains: "weather", Reply: "The mock forecast: sunny with a chance of unit tests."},
+		{Match: rules.Match{Contains: "hello"}, Response: rules.Response{Text: "Hi! This is a mocked assistant response."}},
+		{Match: rules.Match{Contains: "weather"}, Response: rules.Response{Text: "The mock forecast: sunny with a chance of unit tests."}},
 	})
 	manager := remote.NewManager(initialProvidersFromEnv())
-	return &Server{handlers: api.NewHandlers(store, manager)}
+	faultStore := faults.NewStore(nil)
+	rec := recorder.New()
+	budgetStore := budgets.NewStore(nil)
+	counter := tokens.FromEnv()
+	attachStorage(manager, store)
+	return &Server{
+		handlers:    api.NewHandlers(store, manager, faultStore, rec, budgetStore, counter),
+		faultStore:  faultStore,
+		budgetStore: budgetStore,
+		manager:     manager,
+		tokens:      counter,
+	}
 }
 
 func (s *Server) Routes() http.Handler {
@@ -37,7 +62,15 @@ func (s *Server) Routes() http.Handler {
 	mux.HandleFunc("/api/version", s.handlers.OllamaVersion)
 	mux.HandleFunc("/v1/rules", s.handlers.Rules)
 	mux.HandleFunc("/v1/providers", s.handlers.Providers)
-	return loggingMiddleware(mux)
+	mux.HandleFunc("/v1/providers/health", s.handlers.ProvidersHealth)
+	mux.HandleFunc("/v1/faults", s.handlers.Faults)
+	mux.HandleFunc("/v1/recordings/start", s.handlers.Recordings)
+	mux.HandleFunc("/v1/recordings/stop", s.handlers.RecordingsStop)
+	mux.HandleFunc("/v1/recordings/mode", s.handlers.Recordings)
+	mux.HandleFunc("/v1/budgets", s.handlers.Budgets)
+	mux.HandleFunc("/v1/budgets/usage", s.handlers.BudgetsUsage)
+	budgeted := budgets.Middleware(s.budgetStore, s.tokens, s.manager)(faults.Middleware(s.faultStore)(mux))
+	return loggingMiddleware(budgeted)
 }
 
 func (s *Server) ListenAndServe() error {
@@ -53,6 +86,44 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// attachStorage wires persistent storage into manager and ruleStore when
+// CHATMOCK_STORAGE_DIR is set, so providers and rules configured via the
+// /v1/providers and /v1/rules PUT endpoints survive a restart. Documents
+// are encrypted at rest with AES-256-GCM when CHATMOCK_STORAGE_KEY is also
+// set. A background watcher picks up the on-disk JSON files being edited
+// outside the API and hot-reloads the affected manager.
+func attachStorage(manager *remote.Manager, ruleStore *rules.Store) {
+	dir := strings.TrimSpace(os.Getenv("CHATMOCK_STORAGE_DIR"))
+	if dir == "" {
+		return
+	}
+	var store storage.Store = storage.NewFileStore(dir)
+	if key := os.Getenv("CHATMOCK_STORAGE_KEY"); strings.TrimSpace(key) != "" {
+		store = storage.NewEncryptedStore(store, key)
+	}
+
+	if err := manager.AttachStorage(store); err != nil {
+		log.Printf("storage: failed to load persisted providers: %v", err)
+	}
+	if err := ruleStore.AttachStorage(store); err != nil {
+		log.Printf("storage: failed to load persisted rules: %v", err)
+	}
+
+	watcher := storage.NewWatcher(dir, storagePollInterval, func(key string) {
+		switch key {
+		case "providers":
+			if err := manager.ReloadFromStorage(); err != nil {
+				log.Printf("storage: failed to reload providers: %v", err)
+			}
+		case "rules":
+			if err := ruleStore.ReloadFromStorage(); err != nil {
+				log.Printf("storage: failed to reload rules: %v", err)
+			}
+		}
+	})
+	watcher.Start()
+}
+
 func initialProvidersFromEnv() []remote.Provider {
 	providers := make([]remote.Provider, 0, 3)
 
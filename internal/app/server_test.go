@@ -3,11 +3,16 @@ package app
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"chatmock/internal/chat"
+	"chatmock/internal/faults"
 	"chatmock/internal/rules"
 )
 
@@ -80,7 +85,7 @@ func TestChatCompletionWithDefaultRule(t *testing.T) {
 
 func TestRulesUpdate(t *testing.T) {
 	srv := NewServer()
-	update := map[string]any{"rules": []rules.Rule{{Contains: "pizza", Reply: "Mock says: pizza time."}}}
+	update := map[string]any{"rules": []rules.Rule{{Match: rules.Match{Contains: "pizza"}, Response: rules.Response{Text: "Mock says: pizza time."}}}}
 	body, _ := json.Marshal(update)
 
 	updateReq := httptest.NewRequest(http.MethodPut, "/v1/rules", bytes.NewReader(body))
@@ -92,6 +97,34 @@ func TestRulesUpdate(t *testing.T) {
 	}
 }
 
+func TestRuleTemplateRendersCaptureGroups(t *testing.T) {
+	srv := NewServer()
+	update := map[string]any{"rules": []rules.Rule{{
+		Match:    rules.Match{Regex: `\bticket #(\d+)\b`},
+		Response: rules.Response{Template: "Looking into ticket {{.Match1}} for {{.Model}}."},
+	}}}
+	body, _ := json.Marshal(update)
+	updateReq := httptest.NewRequest(http.MethodPut, "/v1/rules", bytes.NewReader(body))
+	updateRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(updateRR, updateReq)
+	if updateRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 on rule update, got %d", updateRR.Code)
+	}
+
+	payload := chat.CompletionRequest{Model: "gpt-mock-1", Messages: []chat.Message{{Role: "user", Content: "please check ticket #482"}}}
+	chatBody, _ := json.Marshal(payload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", chatRR.Code)
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("Looking into ticket 482 for gpt-mock-1.")) {
+		t.Fatalf("expected rendered template, got %s", chatRR.Body.String())
+	}
+}
+
 func TestOllamaProviderProxy(t *testing.T) {
 	ollamaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/chat" {
@@ -134,6 +167,292 @@ func TestOllamaProviderProxy(t *testing.T) {
 	}
 }
 
+func TestModelsListsRealProviderModels(t *testing.T) {
+	ollamaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama3.1"},{"name":"mistral"}]}`))
+	}))
+	defer ollamaSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "ollama",
+		"kind":         "ollama",
+		"base_url":     ollamaSrv.URL,
+		"model_prefix": "ollama/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	modelsReq := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	modelsRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(modelsRR, modelsReq)
+
+	if modelsRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", modelsRR.Code, modelsRR.Body.String())
+	}
+	if !bytes.Contains(modelsRR.Body.Bytes(), []byte("ollama/llama3.1")) {
+		t.Fatalf("expected real model id, got %s", modelsRR.Body.String())
+	}
+	if bytes.Contains(modelsRR.Body.Bytes(), []byte("ollama/*")) {
+		t.Fatalf("expected placeholder to be replaced by real models, got %s", modelsRR.Body.String())
+	}
+}
+
+func TestModelsFallsBackToPlaceholderWhenListingFails(t *testing.T) {
+	downSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	downSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "ollama",
+		"kind":         "ollama",
+		"base_url":     downSrv.URL,
+		"model_prefix": "ollama/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	modelsReq := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	modelsRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(modelsRR, modelsReq)
+
+	if modelsRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", modelsRR.Code, modelsRR.Body.String())
+	}
+	if !bytes.Contains(modelsRR.Body.Bytes(), []byte("ollama/*")) {
+		t.Fatalf("expected placeholder fallback, got %s", modelsRR.Body.String())
+	}
+}
+
+func TestChatCompletionStreaming(t *testing.T) {
+	srv := NewServer()
+	payload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "hello there"}}, Stream: true}
+	body, _ := json.Marshal(payload)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	body2 := rr.Body.String()
+	if !bytes.Contains([]byte(body2), []byte("data: ")) {
+		t.Fatalf("expected SSE data lines, got %s", body2)
+	}
+	if !bytes.Contains([]byte(body2), []byte("data: [DONE]")) {
+		t.Fatalf("expected terminal [DONE] marker, got %s", body2)
+	}
+}
+
+func TestOllamaChatStreamingNDJSON(t *testing.T) {
+	srv := NewServer()
+	payload := map[string]any{"model": "gpt-mock-1", "messages": []map[string]string{{"role": "user", "content": "hello"}}, "stream": true}
+	body, _ := json.Marshal(payload)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected application/x-ndjson, got %s", ct)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"done":true`)) {
+		t.Fatalf("expected final done line, got %s", rr.Body.String())
+	}
+}
+
+func TestProviderFailoverOnServerError(t *testing.T) {
+	failingSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingSrv.Close()
+
+	healthySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"codex-2","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"from backup"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer healthySrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{
+		{"name": "codex-primary", "kind": "codex", "base_url": failingSrv.URL, "model_prefix": "codex/", "priority": 0},
+		{"name": "codex-backup", "kind": "codex", "base_url": healthySrv.URL, "model_prefix": "codex/", "priority": 1},
+	}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from backup")) {
+		t.Fatalf("expected failover to backup provider, got %s", chatRR.Body.String())
+	}
+
+	healthReq := httptest.NewRequest(http.MethodGet, "/v1/providers/health", nil)
+	healthRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(healthRR, healthReq)
+	if healthRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", healthRR.Code)
+	}
+	if !bytes.Contains(healthRR.Body.Bytes(), []byte("codex-primary")) {
+		t.Fatalf("expected primary provider health entry, got %s", healthRR.Body.String())
+	}
+}
+
+func TestStreamingProviderFailoverOnServerError(t *testing.T) {
+	failingSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingSrv.Close()
+
+	healthySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"from backup\"}}]}\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	defer healthySrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{
+		{"name": "codex-primary", "kind": "codex", "base_url": failingSrv.URL, "model_prefix": "codex/", "priority": 0},
+		{"name": "codex-backup", "kind": "codex", "base_url": healthySrv.URL, "model_prefix": "codex/", "priority": 1},
+	}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}, "stream": true}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from backup")) {
+		t.Fatalf("expected failover to backup provider, got %s", chatRR.Body.String())
+	}
+
+	healthReq := httptest.NewRequest(http.MethodGet, "/v1/providers/health", nil)
+	healthRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(healthRR, healthReq)
+	if healthRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", healthRR.Code)
+	}
+	if !bytes.Contains(healthRR.Body.Bytes(), []byte("codex-primary")) {
+		t.Fatalf("expected primary provider health entry, got %s", healthRR.Body.String())
+	}
+}
+
+func TestFaultInjectedStatus(t *testing.T) {
+	srv := NewServer()
+	faultPayload := map[string]any{"faults": []faults.Fault{
+		{Route: "/v1/chat/completions", StatusCode: http.StatusTooManyRequests, RetryAfterSeconds: 2},
+	}}
+	body, _ := json.Marshal(faultPayload)
+	putReq := httptest.NewRequest(http.MethodPut, "/v1/faults", bytes.NewReader(body))
+	putRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(putRR, putReq)
+	if putRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 on fault update, got %d", putRR.Code)
+	}
+
+	chatPayload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "hello"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if chatRR.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected Retry-After: 2, got %q", chatRR.Header().Get("Retry-After"))
+	}
+}
+
+func TestFaultTriggerOnRequestThenRecovers(t *testing.T) {
+	srv := NewServer()
+	faultPayload := map[string]any{"faults": []faults.Fault{
+		{Name: "flaky", Route: "/v1/chat/completions", StatusCode: http.StatusInternalServerError, TriggerOnRequest: 2},
+	}}
+	body, _ := json.Marshal(faultPayload)
+	putReq := httptest.NewRequest(http.MethodPut, "/v1/faults", bytes.NewReader(body))
+	putRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(putRR, putReq)
+
+	chatPayload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "hello"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+
+	wantCodes := []int{http.StatusOK, http.StatusInternalServerError, http.StatusOK}
+	for i, want := range wantCodes {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+		rr := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i+1, want, rr.Code)
+		}
+	}
+}
+
+func TestFaultTruncatesStream(t *testing.T) {
+	srv := NewServer()
+	rulesPayload := map[string]any{"rules": []rules.Rule{
+		{Match: rules.Match{Contains: "hello"}, Response: rules.Response{Text: "one two three four five six"}},
+	}}
+	rulesBody, _ := json.Marshal(rulesPayload)
+	rulesReq := httptest.NewRequest(http.MethodPut, "/v1/rules", bytes.NewReader(rulesBody))
+	rulesRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rulesRR, rulesReq)
+
+	faultPayload := map[string]any{"faults": []faults.Fault{
+		{Route: "/v1/chat/completions", CutAfterChunks: 2},
+	}}
+	faultBody, _ := json.Marshal(faultPayload)
+	faultReq := httptest.NewRequest(http.MethodPut, "/v1/faults", bytes.NewReader(faultBody))
+	faultRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(faultRR, faultReq)
+
+	chatPayload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "hello"}}, Stream: true}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if strings.Contains(chatRR.Body.String(), "[DONE]") {
+		t.Fatalf("expected stream to be truncated before completion, got %s", chatRR.Body.String())
+	}
+}
+
 func TestCodexProviderProxy(t *testing.T) {
 	codexSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/chat/completions" {
@@ -288,3 +607,948 @@ func TestChatGPTProviderProxy(t *testing.T) {
 		t.Fatalf("expected chatgpt response, got %s", chatRR.Body.String())
 	}
 }
+
+func TestRecordingRecordThenReplay(t *testing.T) {
+	codexSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"codex/gpt-5","choices":[{"index":0,"message":{"role":"assistant","content":"from codex"},"finish_reason":"stop"}]}`))
+	}))
+	defer codexSrv.Close()
+
+	cassettePath := filepath.Join(t.TempDir(), "cassette.ndjson")
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "codex", "kind": "codex", "base_url": codexSrv.URL, "model_prefix": "codex/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	startPayload, _ := json.Marshal(map[string]string{"mode": "record", "path": cassettePath})
+	startReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/start", bytes.NewReader(startPayload))
+	startRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(startRR, startReq)
+	if startRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 starting recording, got %d body=%s", startRR.Code, startRR.Body.String())
+	}
+
+	chatPayload, _ := json.Marshal(map[string]any{"model": "codex/gpt-5", "messages": []map[string]string{{"role": "user", "content": "hi"}}})
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+
+	stopReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/stop", nil)
+	stopRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(stopRR, stopReq)
+	if stopRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 stopping recording, got %d", stopRR.Code)
+	}
+
+	replaySrv := NewServer()
+	modePayload, _ := json.Marshal(map[string]string{"mode": "replay", "path": cassettePath})
+	modeReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/mode", bytes.NewReader(modePayload))
+	modeRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(modeRR, modeReq)
+	if modeRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 entering replay mode, got %d body=%s", modeRR.Code, modeRR.Body.String())
+	}
+
+	replayReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	replayRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(replayRR, replayReq)
+	if replayRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from replay, got %d body=%s", replayRR.Code, replayRR.Body.String())
+	}
+	if !bytes.Contains(replayRR.Body.Bytes(), []byte("from codex")) {
+		t.Fatalf("expected replayed codex response, got %s", replayRR.Body.String())
+	}
+
+	missReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	missRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(missRR, missReq)
+	if missRR.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 on a second replay of an exhausted cassette entry, got %d", missRR.Code)
+	}
+}
+
+func TestStreamingRecordingRecordThenReplay(t *testing.T) {
+	codexSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"from codex stream\"}}]}\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	defer codexSrv.Close()
+
+	cassettePath := filepath.Join(t.TempDir(), "cassette.ndjson")
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "codex", "kind": "codex", "base_url": codexSrv.URL, "model_prefix": "codex/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	startPayload, _ := json.Marshal(map[string]string{"mode": "record", "path": cassettePath})
+	startReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/start", bytes.NewReader(startPayload))
+	startRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(startRR, startReq)
+	if startRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 starting recording, got %d body=%s", startRR.Code, startRR.Body.String())
+	}
+
+	chatPayload, _ := json.Marshal(map[string]any{"model": "codex/gpt-5", "messages": []map[string]string{{"role": "user", "content": "hi"}}, "stream": true})
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from codex stream")) {
+		t.Fatalf("expected streamed codex deltas, got %s", chatRR.Body.String())
+	}
+
+	stopReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/stop", nil)
+	stopRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(stopRR, stopReq)
+	if stopRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 stopping recording, got %d", stopRR.Code)
+	}
+
+	// The replay server has no providers configured at all — a correct
+	// cassette hit never needs to dispatch upstream.
+	replaySrv := NewServer()
+	modePayload, _ := json.Marshal(map[string]string{"mode": "replay", "path": cassettePath})
+	modeReq := httptest.NewRequest(http.MethodPost, "/v1/recordings/mode", bytes.NewReader(modePayload))
+	modeRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(modeRR, modeReq)
+	if modeRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 entering replay mode, got %d body=%s", modeRR.Code, modeRR.Body.String())
+	}
+
+	replayReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	replayRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(replayRR, replayReq)
+	if replayRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from replay, got %d body=%s", replayRR.Code, replayRR.Body.String())
+	}
+	if !bytes.Contains(replayRR.Body.Bytes(), []byte("codex")) || !bytes.Contains(replayRR.Body.Bytes(), []byte("stream")) {
+		t.Fatalf("expected replayed codex stream reconstituted as SSE deltas, got %s", replayRR.Body.String())
+	}
+	if !bytes.Contains(replayRR.Body.Bytes(), []byte("data: [DONE]")) {
+		t.Fatalf("expected terminal [DONE] marker, got %s", replayRR.Body.String())
+	}
+
+	missReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	missRR := httptest.NewRecorder()
+	replaySrv.Routes().ServeHTTP(missRR, missReq)
+	if !bytes.Contains(missRR.Body.Bytes(), []byte("no cassette entry recorded")) {
+		t.Fatalf("expected a cassette-miss error on a second replay of an exhausted entry, got %s", missRR.Body.String())
+	}
+}
+
+func TestBudgetRejectsOverQuotaRequests(t *testing.T) {
+	srv := NewServer()
+	budgetPayload, _ := json.Marshal(map[string]any{"budgets": []map[string]any{{
+		"model": "gpt-mock-1", "requests_per_minute": 1,
+	}}})
+	budgetReq := httptest.NewRequest(http.MethodPut, "/v1/budgets", bytes.NewReader(budgetPayload))
+	budgetRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(budgetRR, budgetReq)
+	if budgetRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 setting budget, got %d body=%s", budgetRR.Code, budgetRR.Body.String())
+	}
+
+	chatPayload, _ := json.Marshal(map[string]any{"model": "gpt-mock-1", "messages": []map[string]string{{"role": "user", "content": "hi"}}})
+
+	firstReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	firstRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(firstRR, firstReq)
+	if firstRR.Code != http.StatusOK {
+		t.Fatalf("expected the first request within quota to succeed, got %d", firstRR.Code)
+	}
+
+	secondReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	secondRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(secondRR, secondReq)
+	if secondRR.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the budget is exhausted, got %d", secondRR.Code)
+	}
+	if secondRR.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected a Retry-After header on a budget-exceeded response")
+	}
+}
+
+func TestBudgetsUsageEndpoint(t *testing.T) {
+	srv := NewServer()
+	budgetPayload, _ := json.Marshal(map[string]any{"budgets": []map[string]any{{"model": "gpt-mock-1", "tokens_per_minute": 1000}}})
+	budgetReq := httptest.NewRequest(http.MethodPut, "/v1/budgets", bytes.NewReader(budgetPayload))
+	budgetRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(budgetRR, budgetReq)
+
+	chatPayload, _ := json.Marshal(map[string]any{"model": "gpt-mock-1", "messages": []map[string]string{{"role": "user", "content": "hi"}}})
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatPayload))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", chatRR.Code)
+	}
+
+	usageReq := httptest.NewRequest(http.MethodGet, "/v1/budgets/usage", nil)
+	usageRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(usageRR, usageReq)
+	if usageRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", usageRR.Code)
+	}
+	if !bytes.Contains(usageRR.Body.Bytes(), []byte("requests_this_minute")) {
+		t.Fatalf("expected usage snapshot fields, got %s", usageRR.Body.String())
+	}
+}
+
+func TestChatCompletionUsageIsAlwaysPopulated(t *testing.T) {
+	srv := NewServer()
+	payload := map[string]any{"model": "gpt-mock-1", "messages": []map[string]string{{"role": "user", "content": "hello there"}}}
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	srv.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp chat.CompletionResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Usage.PromptTokens == 0 || resp.Usage.TotalTokens == 0 {
+		t.Fatalf("expected non-zero usage for a mock-rule reply, got %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicProviderProxy(t *testing.T) {
+	anthropicSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "anthropic-key" {
+			t.Fatalf("missing x-api-key header, got: %s", r.Header.Get("x-api-key"))
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Fatalf("missing anthropic-version header")
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if payload["system"] != "be helpful" {
+			t.Fatalf("expected system prompt pulled out of messages, got %v", payload["system"])
+		}
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"from anthropic"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":2}}`))
+	}))
+	defer anthropicSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "anthropic",
+		"kind":         "anthropic",
+		"base_url":     anthropicSrv.URL,
+		"api_key":      "anthropic-key",
+		"model_prefix": "anthropic/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "anthropic/claude-mock", "messages": []map[string]string{
+		{"role": "system", "content": "be helpful"},
+		{"role": "user", "content": "hi"},
+	}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from anthropic")) {
+		t.Fatalf("expected anthropic response, got %s", chatRR.Body.String())
+	}
+}
+
+func TestAnthropicProviderToolUse(t *testing.T) {
+	anthropicSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"SF"}}],"stop_reason":"tool_use","usage":{"input_tokens":3,"output_tokens":2}}`))
+	}))
+	defer anthropicSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "anthropic",
+		"kind":         "anthropic",
+		"base_url":     anthropicSrv.URL,
+		"api_key":      "anthropic-key",
+		"model_prefix": "anthropic/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "anthropic/claude-mock", "messages": []map[string]string{{"role": "user", "content": "what's the weather in SF?"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	var resp chat.CompletionResponse
+	if err := json.Unmarshal(chatRR.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("expected tool_calls finish reason, got %+v", resp.Choices)
+	}
+	toolCalls := resp.Choices[0].Message.ToolCalls
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("expected get_weather tool call, got %+v", toolCalls)
+	}
+	if !bytes.Contains([]byte(toolCalls[0].Function.Arguments), []byte(`"city":"SF"`)) {
+		t.Fatalf("expected tool call arguments to carry the input block, got %s", toolCalls[0].Function.Arguments)
+	}
+}
+
+func TestGeminiProviderProxy(t *testing.T) {
+	geminiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1beta/models/") {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("key") != "gemini-key" {
+			t.Fatalf("expected key query param, got: %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"from gemini"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2,"totalTokenCount":5}}`))
+	}))
+	defer geminiSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "gemini",
+		"kind":         "gemini",
+		"base_url":     geminiSrv.URL,
+		"api_key":      "gemini-key",
+		"model_prefix": "gemini/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "gemini/gemini-mock", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from gemini")) {
+		t.Fatalf("expected gemini response, got %s", chatRR.Body.String())
+	}
+}
+
+func TestAzureProviderProxy(t *testing.T) {
+	azureSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt4-deploy/chat/completions" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Fatalf("expected default api-version, got: %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("api-key") != "azure-key" {
+			t.Fatalf("expected api-key header, got: %s", r.Header.Get("api-key"))
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Fatalf("expected no Authorization header, got: %s", auth)
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-azure","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"from azure"}}]}`))
+	}))
+	defer azureSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "azure",
+		"kind":         "azure",
+		"base_url":     azureSrv.URL,
+		"api_key":      "azure-key",
+		"model_prefix": "azure/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "azure/gpt4-deploy", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from azure")) {
+		t.Fatalf("expected azure response, got %s", chatRR.Body.String())
+	}
+}
+
+// Anthropic and Gemini provider streaming was already implemented in
+// chunk0-7; this request's deliverable is the test coverage below, not new
+// production code.
+func TestAnthropicProviderStreaming(t *testing.T) {
+	anthropicSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"from \"}}\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"anthropic stream\"}}\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		flusher.Flush()
+	}))
+	defer anthropicSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "anthropic",
+		"kind":         "anthropic",
+		"base_url":     anthropicSrv.URL,
+		"api_key":      "anthropic-key",
+		"model_prefix": "anthropic/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "anthropic/claude-mock", "messages": []map[string]string{{"role": "user", "content": "hi"}}, "stream": true}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from ")) || !bytes.Contains(chatRR.Body.Bytes(), []byte("anthropic stream")) {
+		t.Fatalf("expected streamed anthropic deltas, got %s", chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("data: [DONE]")) {
+		t.Fatalf("expected terminal [DONE] marker, got %s", chatRR.Body.String())
+	}
+}
+
+func TestGeminiProviderStreaming(t *testing.T) {
+	geminiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `[{"candidates":[{"content":{"parts":[{"text":"from "}]}}]}`)
+		flusher.Flush()
+		_, _ = io.WriteString(w, `,{"candidates":[{"content":{"parts":[{"text":"gemini stream"}]},"finishReason":"STOP"}]}]`)
+		flusher.Flush()
+	}))
+	defer geminiSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name":         "gemini",
+		"kind":         "gemini",
+		"base_url":     geminiSrv.URL,
+		"api_key":      "gemini-key",
+		"model_prefix": "gemini/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "gemini/gemini-mock", "messages": []map[string]string{{"role": "user", "content": "hi"}}, "stream": true}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from ")) || !bytes.Contains(chatRR.Body.Bytes(), []byte("gemini stream")) {
+		t.Fatalf("expected streamed gemini deltas, got %s", chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("data: [DONE]")) {
+		t.Fatalf("expected terminal [DONE] marker, got %s", chatRR.Body.String())
+	}
+}
+
+func TestProvidersGetMasksAnthropicAndGeminiKeys(t *testing.T) {
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{
+		{"name": "anthropic", "kind": "anthropic", "base_url": "https://example.com", "api_key": "anthropic-secret", "model_prefix": "anthropic/"},
+		{"name": "gemini", "kind": "gemini", "base_url": "https://example.com", "api_key": "gemini-secret", "model_prefix": "gemini/"},
+	}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	getReq := httptest.NewRequest(http.MethodGet, "/v1/providers", nil)
+	getRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(getRR, getReq)
+
+	if getRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", getRR.Code)
+	}
+	if bytes.Contains(getRR.Body.Bytes(), []byte("anthropic-secret")) {
+		t.Fatalf("anthropic api key leaked in response: %s", getRR.Body.String())
+	}
+	if bytes.Contains(getRR.Body.Bytes(), []byte("gemini-secret")) || bytes.Contains(getRR.Body.Bytes(), []byte("?key=")) {
+		t.Fatalf("gemini api key leaked in response: %s", getRR.Body.String())
+	}
+	if bytes.Count(getRR.Body.Bytes(), []byte("\"has_api_key\":true")) != 2 {
+		t.Fatalf("expected has_api_key=true for both providers, got %s", getRR.Body.String())
+	}
+}
+
+func TestChatCompletionMockRuleToolCalls(t *testing.T) {
+	srv := NewServer()
+	update := map[string]any{"rules": []map[string]any{{
+		"match": map[string]any{"contains": "weather in"},
+		"response": map[string]any{
+			"tool_calls": []map[string]any{{
+				"id":   "call_1",
+				"type": "function",
+				"function": map[string]any{
+					"name":      "get_weather",
+					"arguments": `{"city":"Paris"}`,
+				},
+			}},
+		},
+	}}}
+	updateBody, _ := json.Marshal(update)
+	updateReq := httptest.NewRequest(http.MethodPut, "/v1/rules", bytes.NewReader(updateBody))
+	updateRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(updateRR, updateReq)
+	if updateRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 on rule update, got %d", updateRR.Code)
+	}
+
+	payload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "what's the weather in Paris?"}}}
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	var resp chat.CompletionResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("expected tool_calls finish reason, got %+v", resp.Choices)
+	}
+	if len(resp.Choices[0].Message.ToolCalls) != 1 || resp.Choices[0].Message.ToolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("expected get_weather tool call, got %+v", resp.Choices[0].Message.ToolCalls)
+	}
+}
+
+func TestStreamChatCompletionMockRuleToolCalls(t *testing.T) {
+	srv := NewServer()
+	update := map[string]any{"rules": []map[string]any{{
+		"match": map[string]any{"contains": "weather in"},
+		"response": map[string]any{
+			"tool_calls": []map[string]any{{
+				"id":   "call_1",
+				"type": "function",
+				"function": map[string]any{
+					"name":      "get_weather",
+					"arguments": `{"city":"Paris"}`,
+				},
+			}},
+		},
+	}}}
+	updateBody, _ := json.Marshal(update)
+	updateReq := httptest.NewRequest(http.MethodPut, "/v1/rules", bytes.NewReader(updateBody))
+	updateRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(updateRR, updateReq)
+	if updateRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 on rule update, got %d", updateRR.Code)
+	}
+
+	payload := chat.CompletionRequest{Messages: []chat.Message{{Role: "user", Content: "what's the weather in Paris?"}}, Stream: true}
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rr.Code, rr.Body.String())
+	}
+	out := rr.Body.String()
+	if !strings.Contains(out, `"tool_calls"`) || !strings.Contains(out, "get_weather") {
+		t.Fatalf("expected a tool_calls delta chunk, got %s", out)
+	}
+	if !strings.Contains(out, `"finish_reason":"tool_calls"`) {
+		t.Fatalf("expected a tool_calls finish reason chunk, got %s", out)
+	}
+}
+
+func TestChatGPTProviderProxyToolCalls(t *testing.T) {
+	chatgptSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if _, ok := payload["tools"]; !ok {
+			t.Fatalf("expected tools to be forwarded, got %v", payload)
+		}
+		_, _ = w.Write([]byte(`{"output":[{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}]}`))
+	}))
+	defer chatgptSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "chatgpt", "kind": "chatgpt", "base_url": chatgptSrv.URL,
+		"access_token": "chatgpt-token", "model_prefix": "chatgpt/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := chat.CompletionRequest{
+		Model:    "chatgpt/gpt-5",
+		Messages: []chat.Message{{Role: "user", Content: "what's the weather in Paris?"}},
+		Tools:    []chat.Tool{{Type: "function", Function: chat.ToolFunction{Name: "get_weather"}}},
+	}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	var resp chat.CompletionResponse
+	if err := json.Unmarshal(chatRR.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("expected tool_calls finish reason, got %+v", resp.Choices)
+	}
+	if len(resp.Choices[0].Message.ToolCalls) != 1 || resp.Choices[0].Message.ToolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("expected get_weather tool call, got %+v", resp.Choices[0].Message.ToolCalls)
+	}
+}
+
+func TestChatGPTProviderStreamingToolCalls(t *testing.T) {
+	chatgptSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "data: {\"type\":\"response.output_item.done\",\"item\":{\"type\":\"function_call\",\"call_id\":\"call_1\",\"name\":\"get_weather\",\"arguments\":\"{\\\"city\\\":\\\"Paris\\\"}\"}}\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "data: {\"type\":\"response.completed\"}\n\n")
+		flusher.Flush()
+	}))
+	defer chatgptSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "chatgpt", "kind": "chatgpt", "base_url": chatgptSrv.URL,
+		"access_token": "chatgpt-token", "model_prefix": "chatgpt/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := chat.CompletionRequest{
+		Model:    "chatgpt/gpt-5",
+		Messages: []chat.Message{{Role: "user", Content: "what's the weather in Paris?"}},
+		Stream:   true,
+	}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	out := chatRR.Body.String()
+	if !strings.Contains(out, `"tool_calls"`) || !strings.Contains(out, "get_weather") {
+		t.Fatalf("expected a tool_calls delta chunk, got %s", out)
+	}
+	if !strings.Contains(out, `"finish_reason":"tool_calls"`) {
+		t.Fatalf("expected a tool_calls finish reason chunk, got %s", out)
+	}
+}
+
+func TestOpenAIProviderStreamingFragmentedToolCalls(t *testing.T) {
+	openaiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		// Real OpenAI-compatible streaming fragments a tool call across many
+		// chunks: only the first fragment at a given index carries
+		// id/type/function.name, later fragments at that index carry only a
+		// partial function.arguments string to be concatenated.
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`+"\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`+"\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`+"\n\n")
+		flusher.Flush()
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	defer openaiSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "openai-frag", "kind": "openai", "base_url": openaiSrv.URL, "model_prefix": "openai/",
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := chat.CompletionRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []chat.Message{{Role: "user", Content: "what's the weather in Paris?"}},
+		Stream:   true,
+	}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	out := chatRR.Body.String()
+
+	var toolCallChunks []chat.CompletionChunk
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "data: ") || strings.HasPrefix(line, "data: [DONE]") {
+			continue
+		}
+		var c chat.CompletionChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &c); err != nil {
+			continue
+		}
+		if len(c.Choices) > 0 && len(c.Choices[0].Delta.ToolCalls) > 0 {
+			toolCallChunks = append(toolCallChunks, c)
+		}
+	}
+	if len(toolCallChunks) != 1 {
+		t.Fatalf("expected exactly one reassembled tool_calls delta chunk, got %d: %s", len(toolCallChunks), out)
+	}
+	calls := toolCallChunks[0].Choices[0].Delta.ToolCalls
+	if len(calls) != 1 {
+		t.Fatalf("expected exactly one reassembled tool call, got %d", len(calls))
+	}
+	call := calls[0]
+	if call.ID != "call_1" || call.Type != "function" || call.Function.Name != "get_weather" {
+		t.Fatalf("expected id/type/name carried from the first fragment, got %+v", call)
+	}
+	if call.Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("expected fragmented arguments to be concatenated, got %q", call.Function.Arguments)
+	}
+	if !strings.Contains(out, `"finish_reason":"tool_calls"`) {
+		t.Fatalf("expected a tool_calls finish reason chunk, got %s", out)
+	}
+}
+
+func TestProviderRetryPolicyRetriesBeforeFailingOver(t *testing.T) {
+	var attempts int
+	flakySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"codex-1","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"from flaky"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer flakySrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "codex-flaky", "kind": "codex", "base_url": flakySrv.URL, "model_prefix": "codex/",
+		"retry_policy": map[string]any{"max_attempts": 3, "base_backoff_ms": 1, "max_backoff_ms": 2},
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from flaky")) {
+		t.Fatalf("expected same-provider retry to eventually succeed, got %s", chatRR.Body.String())
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts against the flaky provider, got %d", attempts)
+	}
+}
+
+func TestProvidersGetSurfacesBreakerState(t *testing.T) {
+	failingSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{{
+		"name": "codex-brittle", "kind": "codex", "base_url": failingSrv.URL, "model_prefix": "codex/",
+		"retry_policy": map[string]any{"failure_threshold": 1},
+	}}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+	if chatRR.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the only provider's upstream 503 to be returned, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+
+	providersReq := httptest.NewRequest(http.MethodGet, "/v1/providers", nil)
+	providersRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providersRR, providersReq)
+	if providersRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", providersRR.Code)
+	}
+	if !bytes.Contains(providersRR.Body.Bytes(), []byte(`"breaker_state":"open"`)) {
+		t.Fatalf("expected breaker_state open after a single failure with failure_threshold=1, got %s", providersRR.Body.String())
+	}
+	if !bytes.Contains(providersRR.Body.Bytes(), []byte(`"consecutive_failures":1`)) {
+		t.Fatalf("expected consecutive_failures to be surfaced, got %s", providersRR.Body.String())
+	}
+}
+
+func TestProviderPriorityTiersFailOverInOrder(t *testing.T) {
+	var primaryHits, fallbackHits int
+	primarySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primarySrv.Close()
+
+	fallbackSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackHits++
+		_, _ = w.Write([]byte(`{"id":"codex-2","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"from fallback tier"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer fallbackSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{
+		{"name": "codex-primary", "kind": "codex", "base_url": primarySrv.URL, "model_prefix": "codex/", "priority": 0},
+		{"name": "codex-fallback", "kind": "codex", "base_url": fallbackSrv.URL, "model_prefix": "codex/", "priority": 1},
+	}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+	chatRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(chatRR, chatReq)
+
+	if chatRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+	}
+	if !bytes.Contains(chatRR.Body.Bytes(), []byte("from fallback tier")) {
+		t.Fatalf("expected the priority-1 provider to serve the request, got %s", chatRR.Body.String())
+	}
+	if primaryHits != 1 {
+		t.Fatalf("expected the priority-0 provider to be tried exactly once before failover, got %d", primaryHits)
+	}
+	if fallbackHits != 1 {
+		t.Fatalf("expected the priority-1 provider to be tried exactly once, got %d", fallbackHits)
+	}
+}
+
+func TestProviderWeightedSelectionAmongEqualPriority(t *testing.T) {
+	hits := map[string]int{}
+	var mu sync.Mutex
+	newSrv := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"id":"codex-` + name + `","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"from ` + name + `"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+		}))
+	}
+	heavySrv := newSrv("heavy")
+	defer heavySrv.Close()
+	lightSrv := newSrv("light")
+	defer lightSrv.Close()
+
+	srv := NewServer()
+	providerPayload := map[string]any{"providers": []map[string]any{
+		{"name": "codex-heavy", "kind": "codex", "base_url": heavySrv.URL, "model_prefix": "codex/", "weight": 9},
+		{"name": "codex-light", "kind": "codex", "base_url": lightSrv.URL, "model_prefix": "codex/", "weight": 1},
+	}}
+	providerBody, _ := json.Marshal(providerPayload)
+	providerReq := httptest.NewRequest(http.MethodPut, "/v1/providers", bytes.NewReader(providerBody))
+	providerRR := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(providerRR, providerReq)
+
+	chatPayload := map[string]any{"model": "codex/gpt-5-codex", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	chatBody, _ := json.Marshal(chatPayload)
+	for i := 0; i < 50; i++ {
+		chatReq := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(chatBody))
+		chatRR := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(chatRR, chatReq)
+		if chatRR.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d body=%s", chatRR.Code, chatRR.Body.String())
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["heavy"]+hits["light"] != 50 {
+		t.Fatalf("expected 50 total requests split across peers, got %+v", hits)
+	}
+	if hits["heavy"] <= hits["light"] {
+		t.Fatalf("expected the weight=9 provider to be picked more often than the weight=1 provider over 50 tries, got %+v", hits)
+	}
+}
@@ -0,0 +1,86 @@
+package budgets
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"chatmock/internal/chat"
+	"chatmock/internal/remote"
+	"chatmock/internal/tokens"
+)
+
+// Middleware enforces store's budgets before a request reaches the rule
+// engine or a remote provider, returning 429 with a Retry-After header
+// when a request/token-per-minute limit or daily token cap would be
+// exceeded. Token cost is estimated from the request body's prompt
+// alone, since the eventual completion length isn't known until after
+// the handler runs.
+func Middleware(store *Store, counter tokens.Counter, manager *remote.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(store.All()) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			model, messages := peekRequest(r)
+			providerName := ""
+			if manager != nil {
+				if p, _, ok := manager.Match(model); ok {
+					providerName = p.Name
+				}
+			}
+			promptTokens := tokens.CountMessages(counter, model, messages)
+
+			if ok, retryAfter := store.Reserve(providerName, model, promptTokens); !ok {
+				writeBudgetExceeded(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// peekRequest extracts the model and message-shaped content out of the
+// JSON request body without consuming it, so the wrapped handler still
+// sees a fresh body. It understands the chat/completions and
+// Ollama-style {model, messages} shape as well as the legacy
+// {model, prompt} completions shape.
+func peekRequest(r *http.Request) (model string, messages []chat.Message) {
+	if r.Body == nil {
+		return "", nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	var probe struct {
+		Model    string         `json:"model"`
+		Messages []chat.Message `json:"messages"`
+		Prompt   string         `json:"prompt"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return "", nil
+	}
+	if len(probe.Messages) > 0 {
+		return probe.Model, probe.Messages
+	}
+	if probe.Prompt != "" {
+		return probe.Model, []chat.Message{{Role: "user", Content: probe.Prompt}}
+	}
+	return probe.Model, nil
+}
+
+func writeBudgetExceeded(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterHeader(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": "budget exceeded", "type": "chatmock_budget_exceeded"},
+	})
+}
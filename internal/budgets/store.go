@@ -0,0 +1,161 @@
+// Package budgets implements optional rate and volume limiting so
+// chatmock clients can be tested against quota pressure: a rolling
+// requests-per-minute and tokens-per-minute limit plus a daily token
+// cap, scoped by provider and/or model.
+package budgets
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Budget scopes a set of limits to a provider and/or model. An empty
+// Provider or Model matches any value, so a budget with both empty acts
+// as a catch-all.
+type Budget struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	DailyTokenCap     int `json:"daily_token_cap,omitempty"`
+}
+
+// Usage is a point-in-time snapshot of one budget's rolling counters,
+// returned by GET /v1/budgets/usage.
+type Usage struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	RequestsThisMinute int `json:"requests_this_minute"`
+	TokensThisMinute   int `json:"tokens_this_minute"`
+	TokensToday        int `json:"tokens_today"`
+
+	MinuteResetAt time.Time `json:"minute_reset_at"`
+	DayResetAt    time.Time `json:"day_reset_at"`
+}
+
+type window struct {
+	minuteStart        time.Time
+	dayStart           time.Time
+	requestsThisMinute int
+	tokensThisMinute   int
+	tokensToday        int
+}
+
+type Store struct {
+	mu       sync.Mutex
+	budgets  []Budget
+	counters map[int]*window
+}
+
+func NewStore(seed []Budget) *Store {
+	return &Store{budgets: append([]Budget(nil), seed...), counters: make(map[int]*window)}
+}
+
+func (s *Store) Set(items []Budget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budgets = append([]Budget(nil), items...)
+	s.counters = make(map[int]*window)
+}
+
+func (s *Store) All() []Budget {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Budget(nil), s.budgets...)
+}
+
+// Reserve finds the first budget scoped to provider/model and either
+// admits the request, rolling forward its minute/day windows and
+// incrementing their counters by tokens, or rejects it with the
+// duration the caller should wait before retrying. A request that
+// matches no budget is always admitted.
+func (s *Store) Reserve(provider, model string, tokens int) (ok bool, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, budget, found := s.match(provider, model)
+	if !found {
+		return true, 0
+	}
+	now := time.Now()
+	w, exists := s.counters[index]
+	if !exists {
+		w = &window{minuteStart: now, dayStart: now}
+		s.counters[index] = w
+	}
+	if now.Sub(w.minuteStart) >= time.Minute {
+		w.minuteStart = now
+		w.requestsThisMinute = 0
+		w.tokensThisMinute = 0
+	}
+	if now.Sub(w.dayStart) >= 24*time.Hour {
+		w.dayStart = now
+		w.tokensToday = 0
+	}
+
+	if budget.RequestsPerMinute > 0 && w.requestsThisMinute+1 > budget.RequestsPerMinute {
+		return false, time.Minute - now.Sub(w.minuteStart)
+	}
+	if budget.TokensPerMinute > 0 && w.tokensThisMinute+tokens > budget.TokensPerMinute {
+		return false, time.Minute - now.Sub(w.minuteStart)
+	}
+	if budget.DailyTokenCap > 0 && w.tokensToday+tokens > budget.DailyTokenCap {
+		return false, 24*time.Hour - now.Sub(w.dayStart)
+	}
+
+	w.requestsThisMinute++
+	w.tokensThisMinute += tokens
+	w.tokensToday += tokens
+	return true, 0
+}
+
+// Usage returns a snapshot of every configured budget's current window
+// counters, in configured order.
+func (s *Store) Usage() []Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Usage, 0, len(s.budgets))
+	for i, b := range s.budgets {
+		u := Usage{Provider: b.Provider, Model: b.Model}
+		if w, ok := s.counters[i]; ok {
+			u.RequestsThisMinute = w.requestsThisMinute
+			u.TokensThisMinute = w.tokensThisMinute
+			u.TokensToday = w.tokensToday
+			u.MinuteResetAt = w.minuteStart.Add(time.Minute)
+			u.DayResetAt = w.dayStart.Add(24 * time.Hour)
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+func (s *Store) match(provider, model string) (int, Budget, bool) {
+	for i, b := range s.budgets {
+		if strings.TrimSpace(b.Provider) != "" && b.Provider != provider {
+			continue
+		}
+		if strings.TrimSpace(b.Model) != "" && b.Model != model {
+			continue
+		}
+		return i, b, true
+	}
+	return 0, Budget{}, false
+}
+
+// retryAfterHeader renders d as the whole-second value a Retry-After
+// header expects, rounding up so callers never retry a moment too
+// early.
+func retryAfterHeader(d time.Duration) string {
+	seconds := int(d.Seconds())
+	if d%time.Second != 0 {
+		seconds++
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,57 @@
+package budgets
+
+import "testing"
+
+func TestReserveRequestsPerMinute(t *testing.T) {
+	store := NewStore([]Budget{{Model: "gpt-mock-1", RequestsPerMinute: 1}})
+
+	if ok, _ := store.Reserve("", "gpt-mock-1", 0); !ok {
+		t.Fatalf("expected the first request to be admitted")
+	}
+	if ok, retryAfter := store.Reserve("", "gpt-mock-1", 0); ok || retryAfter <= 0 {
+		t.Fatalf("expected the second request within the same minute to be rejected, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+}
+
+func TestReserveTokensPerMinute(t *testing.T) {
+	store := NewStore([]Budget{{Model: "gpt-mock-1", TokensPerMinute: 100}})
+
+	if ok, _ := store.Reserve("", "gpt-mock-1", 60); !ok {
+		t.Fatalf("expected 60 tokens to be admitted under a 100 token budget")
+	}
+	if ok, _ := store.Reserve("", "gpt-mock-1", 60); ok {
+		t.Fatalf("expected 60 + 60 tokens to exceed a 100 token budget")
+	}
+}
+
+func TestReserveDailyTokenCap(t *testing.T) {
+	store := NewStore([]Budget{{Model: "gpt-mock-1", DailyTokenCap: 10}})
+
+	if ok, _ := store.Reserve("", "gpt-mock-1", 10); !ok {
+		t.Fatalf("expected 10 tokens to exactly fill a 10 token daily cap")
+	}
+	if ok, _ := store.Reserve("", "gpt-mock-1", 1); ok {
+		t.Fatalf("expected the daily cap to already be exhausted")
+	}
+}
+
+func TestReserveUnscopedRequestIsAlwaysAdmitted(t *testing.T) {
+	store := NewStore([]Budget{{Model: "gpt-mock-1", RequestsPerMinute: 1}})
+
+	if ok, _ := store.Reserve("", "other-model", 0); !ok {
+		t.Fatalf("expected a request for an unbudgeted model to be admitted")
+	}
+}
+
+func TestUsageReflectsReservations(t *testing.T) {
+	store := NewStore([]Budget{{Provider: "codex", Model: "codex/gpt-5", TokensPerMinute: 1000}})
+	store.Reserve("codex", "codex/gpt-5", 42)
+
+	usage := store.Usage()
+	if len(usage) != 1 {
+		t.Fatalf("expected one usage entry, got %d", len(usage))
+	}
+	if usage[0].TokensThisMinute != 42 || usage[0].RequestsThisMinute != 1 {
+		t.Fatalf("unexpected usage snapshot: %+v", usage[0])
+	}
+}
@@ -1,14 +1,52 @@
 package chat
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+}
+
+// ToolCall is an OpenAI-style function call emitted by the assistant,
+// either passed through from an upstream provider or synthesized by a
+// rules.Rule mocking an agentic loop.
+type ToolCall struct {
+	// Index identifies which tool call this is within the eventual
+	// tool_calls array. It's only meaningful while reassembling a
+	// fragmented OpenAI/Azure streaming delta (see adapter_openai.go's
+	// StreamChatCompletions) and is left zero everywhere else.
+	Index    int              `json:"index,omitempty"`
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool describes a function a caller makes available to the model, mirroring
+// OpenAI's "tools" request field.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
 }
 
 type CompletionRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
 	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+	ToolChoice  any       `json:"tool_choice,omitempty"`
 }
 
 type Choice struct {
@@ -45,8 +83,9 @@ type ModelInfo struct {
 }
 
 type ResponsesRequest struct {
-	Model string `json:"model"`
-	Input any    `json:"input"`
+	Model  string `json:"model"`
+	Input  any    `json:"input"`
+	Stream bool   `json:"stream,omitempty"`
 }
 
 type ResponsesResponse struct {
@@ -56,3 +95,39 @@ type ResponsesResponse struct {
 	Model      string `json:"model"`
 	OutputText string `json:"output_text"`
 }
+
+// ChunkDelta carries the incremental content of a streamed chat completion,
+// mirroring the OpenAI "choices[0].delta" shape. ToolCalls is sent whole
+// rather than split into per-argument-token deltas, since chatmock doesn't
+// simulate a real model's token-by-token tool-call generation.
+type ChunkDelta struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+type ChunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        ChunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+// CompletionChunk is a single OpenAI-style SSE "data:" payload for
+// /v1/chat/completions and /v1/completions streaming.
+type CompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ResponseStreamEvent mirrors the /v1/responses streaming event envelope
+// ("response.output_text.delta" and "response.completed").
+type ResponseStreamEvent struct {
+	Type       string `json:"type"`
+	ResponseID string `json:"response_id,omitempty"`
+	Delta      string `json:"delta,omitempty"`
+	OutputText string `json:"output_text,omitempty"`
+}
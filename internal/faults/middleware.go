@@ -0,0 +1,129 @@
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware wraps next so that every request is first checked against the
+// fault store. A matching fault can inject latency, a canned error status,
+// malformed JSON, or a truncated stream before (or instead of) next runs.
+func Middleware(store *Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			model := requestModel(r)
+			fault, ok := store.Match(r, model)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if fault.LatencyMS > 0 || fault.LatencyJitterMS > 0 {
+				time.Sleep(jitteredLatency(fault))
+			}
+
+			switch {
+			case fault.StatusCode != 0:
+				writeFaultStatus(w, fault)
+			case fault.MalformedJSON:
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, `{"object": "chat.completion", "choices": [`)
+			case fault.CutAfterChunks > 0:
+				next.ServeHTTP(&truncatingWriter{ResponseWriter: w, maxChunks: fault.CutAfterChunks}, r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// requestModel peeks the "model" field out of the JSON request body
+// without consuming it, so the wrapped handler still sees a fresh body.
+func requestModel(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return ""
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	var probe struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(data, &probe)
+	return probe.Model
+}
+
+func writeFaultStatus(w http.ResponseWriter, f Fault) {
+	if f.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(f.RetryAfterSeconds))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.StatusCode)
+	if f.ErrorBody != "" {
+		_, _ = io.WriteString(w, f.ErrorBody)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": "injected fault", "type": "chatmock_fault"},
+	})
+}
+
+// jitteredLatency combines a fixed base delay with optional normally
+// distributed jitter, per Fault.LatencyMS/LatencyJitterMS.
+func jitteredLatency(f Fault) time.Duration {
+	base := time.Duration(f.LatencyMS) * time.Millisecond
+	if f.LatencyJitterMS <= 0 {
+		return base
+	}
+	jitter := time.Duration(rand.NormFloat64()*float64(f.LatencyJitterMS)) * time.Millisecond
+	d := base + jitter
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// truncatingWriter cuts a streaming response short after maxChunks flushes,
+// hijacking and closing the underlying connection when possible so the
+// client observes a genuine mid-stream disconnect rather than a clean EOF.
+type truncatingWriter struct {
+	http.ResponseWriter
+	maxChunks int
+	flushes   int
+	cut       bool
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if w.cut {
+		return 0, io.ErrClosedPipe
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *truncatingWriter) Flush() {
+	if w.cut {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	w.flushes++
+	if w.flushes < w.maxChunks {
+		return
+	}
+	w.cut = true
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			_ = conn.Close()
+		}
+	}
+}
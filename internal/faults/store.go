@@ -0,0 +1,101 @@
+// Package faults implements a runtime-configurable fault-injection
+// subsystem so chatmock clients can be tested against deterministic
+// failure scenarios: injected status codes, latency, malformed JSON,
+// truncated streams, and "fail the Nth request" counters.
+package faults
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fault describes one failure scenario, scoped to requests matching Route
+// (a glob against the request path) and/or ModelPrefix.
+type Fault struct {
+	Name        string `json:"name,omitempty"`
+	Route       string `json:"route,omitempty"`
+	ModelPrefix string `json:"model_prefix,omitempty"`
+
+	StatusCode        int    `json:"status_code,omitempty"`
+	ErrorBody         string `json:"error_body,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+
+	LatencyMS       int `json:"latency_ms,omitempty"`
+	LatencyJitterMS int `json:"latency_jitter_ms,omitempty"`
+
+	MalformedJSON  bool `json:"malformed_json,omitempty"`
+	CutAfterChunks int  `json:"cut_after_chunks,omitempty"`
+
+	// TriggerOnRequest, when set, applies this fault only to the Nth
+	// (1-indexed) request that otherwise matches Route/ModelPrefix,
+	// letting every other request through untouched so clients can be
+	// tested against "fails once, then recovers" scenarios.
+	TriggerOnRequest int `json:"trigger_on_request,omitempty"`
+}
+
+type Store struct {
+	mu     sync.Mutex
+	faults []Fault
+	counts map[string]int
+}
+
+func NewStore(seed []Fault) *Store {
+	return &Store{faults: append([]Fault(nil), seed...), counts: make(map[string]int)}
+}
+
+func (s *Store) Set(items []Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append([]Fault(nil), items...)
+	s.counts = make(map[string]int)
+}
+
+func (s *Store) All() []Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Fault(nil), s.faults...)
+}
+
+// Match finds the first fault whose Route/ModelPrefix scope the given
+// request and model, advancing its request counter. A fault with
+// TriggerOnRequest set only fires on that exact request number.
+func (s *Store) Match(r *http.Request, model string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if !scopeMatches(f, r, model) {
+			continue
+		}
+		key := faultKey(f, i)
+		s.counts[key]++
+		if f.TriggerOnRequest > 0 && s.counts[key] != f.TriggerOnRequest {
+			continue
+		}
+		return f, true
+	}
+	return Fault{}, false
+}
+
+func scopeMatches(f Fault, r *http.Request, model string) bool {
+	if strings.TrimSpace(f.Route) != "" {
+		ok, err := path.Match(f.Route, r.URL.Path)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	if strings.TrimSpace(f.ModelPrefix) != "" && !strings.HasPrefix(model, f.ModelPrefix) {
+		return false
+	}
+	return true
+}
+
+func faultKey(f Fault, index int) string {
+	if strings.TrimSpace(f.Name) != "" {
+		return f.Name
+	}
+	return "fault-" + strconv.Itoa(index)
+}
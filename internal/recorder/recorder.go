@@ -0,0 +1,225 @@
+// Package recorder implements request/response recording and replay for
+// remote-provider dispatches, so a captured session can later be replayed
+// deterministically without a live upstream (e.g. in CI or offline demos).
+package recorder
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+// Mode controls how a Recorder behaves for each dispatched request.
+type Mode string
+
+const (
+	// ModeOff disables both recording and replay.
+	ModeOff Mode = "off"
+	// ModeRecord appends every live dispatch to the cassette file.
+	ModeRecord Mode = "record"
+	// ModeReplay serves only cassette entries; a miss is an error.
+	ModeReplay Mode = "replay"
+	// ModePassthroughOnMiss serves cassette entries when available and
+	// falls back to a live dispatch otherwise.
+	ModePassthroughOnMiss Mode = "passthrough_on_miss"
+)
+
+// Cassette is one recorded request/response pair, persisted as a single
+// ndjson line.
+type Cassette struct {
+	Signature  string          `json:"signature"`
+	Provider   string          `json:"provider"`
+	Model      string          `json:"model"`
+	Status     int             `json:"status"`
+	Body       json.RawMessage `json:"body"`
+	LatencyMS  int64           `json:"latency_ms"`
+	RecordedAt time.Time       `json:"recorded_at"`
+}
+
+// Recorder tracks the active mode and, depending on mode, either appends
+// recorded dispatches to a cassette file or serves them back from one
+// loaded into memory.
+type Recorder struct {
+	mu     sync.Mutex
+	mode   Mode
+	path   string
+	file   *os.File
+	replay map[string][]Cassette
+}
+
+func New() *Recorder {
+	return &Recorder{mode: ModeOff}
+}
+
+// Mode reports the recorder's current mode.
+func (rec *Recorder) Mode() Mode {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.mode
+}
+
+// Start opens path for append and switches the recorder into record mode.
+func (rec *Recorder) Start(path string) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file != nil {
+		_ = rec.file.Close()
+		rec.file = nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open cassette file: %w", err)
+	}
+	rec.file = f
+	rec.path = path
+	rec.mode = ModeRecord
+	rec.replay = nil
+	return nil
+}
+
+// Stop closes any open cassette file and returns the recorder to ModeOff.
+func (rec *Recorder) Stop() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var err error
+	if rec.file != nil {
+		err = rec.file.Close()
+		rec.file = nil
+	}
+	rec.mode = ModeOff
+	rec.replay = nil
+	return err
+}
+
+// SetMode switches into replay or passthrough-on-miss mode, loading
+// cassette entries from path into memory keyed by Signature.
+func (rec *Recorder) SetMode(mode Mode, path string) error {
+	if mode != ModeReplay && mode != ModePassthroughOnMiss {
+		return fmt.Errorf("recorder: unsupported replay mode %q", mode)
+	}
+	entries, err := loadCassettes(path)
+	if err != nil {
+		return err
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file != nil {
+		_ = rec.file.Close()
+		rec.file = nil
+	}
+	rec.mode = mode
+	rec.path = path
+	rec.replay = entries
+	return nil
+}
+
+func loadCassettes(path string) (map[string][]Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette file: %w", err)
+	}
+	defer f.Close()
+
+	entries := make(map[string][]Cassette)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var c Cassette
+		if err := json.Unmarshal(line, &c); err != nil {
+			return nil, fmt.Errorf("parse cassette line: %w", err)
+		}
+		entries[c.Signature] = append(entries[c.Signature], c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan cassette file: %w", err)
+	}
+	return entries, nil
+}
+
+// Record appends a dispatch outcome to the cassette file. It is a no-op
+// unless the recorder is in ModeRecord.
+func (rec *Recorder) Record(req chat.CompletionRequest, provider string, status int, body []byte, latency time.Duration) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.mode != ModeRecord || rec.file == nil {
+		return
+	}
+	c := Cassette{
+		Signature:  signature(req),
+		Provider:   provider,
+		Model:      req.Model,
+		Status:     status,
+		Body:       append(json.RawMessage(nil), body...),
+		LatencyMS:  latency.Milliseconds(),
+		RecordedAt: time.Now(),
+	}
+	line, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	_, _ = rec.file.Write(line)
+}
+
+// Lookup returns the next unconsumed cassette entry matching req, if the
+// recorder is in a replay mode and one is available.
+func (rec *Recorder) Lookup(req chat.CompletionRequest) (Cassette, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.mode != ModeReplay && rec.mode != ModePassthroughOnMiss {
+		return Cassette{}, false
+	}
+	sig := signature(req)
+	entries := rec.replay[sig]
+	if len(entries) == 0 {
+		return Cassette{}, false
+	}
+	rec.replay[sig] = entries[1:]
+	return entries[0], true
+}
+
+// PassthroughOnMiss reports whether a replay miss should fall back to a
+// live dispatch rather than failing the request.
+func (rec *Recorder) PassthroughOnMiss() bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.mode == ModePassthroughOnMiss
+}
+
+// normalizedRequest is the subset of a request that determines cassette
+// matching; fields like Stream or sampling parameters are intentionally
+// excluded so the same recorded exchange can serve both streaming and
+// non-streaming replays of an otherwise identical conversation.
+type normalizedRequest struct {
+	Model    string              `json:"model"`
+	Messages []normalizedMessage `json:"messages"`
+}
+
+type normalizedMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// signature computes a stable, content-addressed identifier for req so
+// that repeated playback of the same conversation resolves to the same
+// cassette entries.
+func signature(req chat.CompletionRequest) string {
+	norm := normalizedRequest{Model: req.Model, Messages: make([]normalizedMessage, len(req.Messages))}
+	for i, m := range req.Messages {
+		norm.Messages[i] = normalizedMessage{Role: m.Role, Content: m.Content}
+	}
+	data, _ := json.Marshal(norm)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
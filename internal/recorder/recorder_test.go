@@ -0,0 +1,84 @@
+package recorder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.ndjson")
+	req := chat.CompletionRequest{Model: "codex/gpt-5", Messages: []chat.Message{{Role: "user", Content: "hello"}}}
+
+	rec := New()
+	if err := rec.Start(path); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	rec.Record(req, "codex", 200, []byte(`{"id":"chatcmpl-1"}`), 10*time.Millisecond)
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	replay := New()
+	if err := replay.SetMode(ModeReplay, path); err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	cassette, ok := replay.Lookup(req)
+	if !ok {
+		t.Fatalf("expected a cassette hit")
+	}
+	if cassette.Provider != "codex" || cassette.Status != 200 {
+		t.Fatalf("unexpected cassette: %+v", cassette)
+	}
+	if _, ok := replay.Lookup(req); ok {
+		t.Fatalf("expected cassette entry to be consumed after first lookup")
+	}
+}
+
+func TestLookupIgnoresStreamFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.ndjson")
+	base := chat.CompletionRequest{Model: "codex/gpt-5", Messages: []chat.Message{{Role: "user", Content: "hi"}}}
+
+	rec := New()
+	if err := rec.Start(path); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	rec.Record(base, "codex", 200, []byte(`{"id":"chatcmpl-1"}`), 0)
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	replay := New()
+	if err := replay.SetMode(ModePassthroughOnMiss, path); err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	streaming := base
+	streaming.Stream = true
+	if _, ok := replay.Lookup(streaming); !ok {
+		t.Fatalf("expected signature to ignore the Stream flag")
+	}
+}
+
+func TestPassthroughOnMissFallsBackOnMiss(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.ndjson")
+	rec := New()
+	if err := rec.Start(path); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	replay := New()
+	if err := replay.SetMode(ModePassthroughOnMiss, path); err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	if !replay.PassthroughOnMiss() {
+		t.Fatalf("expected PassthroughOnMiss to be true")
+	}
+	if _, ok := replay.Lookup(chat.CompletionRequest{Model: "unseen", Messages: []chat.Message{{Role: "user", Content: "x"}}}); ok {
+		t.Fatalf("expected a miss for an unrecorded request")
+	}
+}
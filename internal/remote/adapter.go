@@ -0,0 +1,50 @@
+package remote
+
+import (
+	"context"
+
+	"chatmock/internal/chat"
+)
+
+// ProviderAdapter implements the wire protocol for one provider kind: how
+// to build and parse chat completion requests, translate streaming deltas,
+// and enumerate the models it actually serves. Kinds register an adapter
+// via Register (from their own init()) so Client dispatches through a
+// lookup instead of a hardcoded switch on Provider.Kind.
+type ProviderAdapter interface {
+	ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error)
+	StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error)
+	Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error)
+}
+
+var adapterRegistry = map[string]func() ProviderAdapter{}
+
+// Register associates kind with a ProviderAdapter factory. Adapter files
+// call this from their own init(), so adding a new provider kind never
+// requires touching Client's dispatch methods.
+func Register(kind string, factory func() ProviderAdapter) {
+	adapterRegistry[kind] = factory
+}
+
+// adapterFor looks up the adapter for kind, falling back to the
+// openai-compatible adapter for unknown kinds, matching normalizeProvider's
+// own default.
+func adapterFor(kind string) ProviderAdapter {
+	if factory, ok := adapterRegistry[kind]; ok {
+		return factory()
+	}
+	return adapterRegistry["openai"]()
+}
+
+func (c *Client) ChatCompletions(ctx context.Context, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	return adapterFor(provider.Kind).ChatCompletions(ctx, c, provider, req, model)
+}
+
+// Models enumerates the real model IDs a provider currently serves. It is
+// best-effort: adapters that can't list models, and upstream calls
+// answered with an error status, return an empty slice; a network or
+// decode failure is returned as an error. Callers fall back to a
+// placeholder in either case.
+func (c *Client) Models(ctx context.Context, provider Provider) ([]chat.ModelInfo, error) {
+	return adapterFor(provider.Kind).Models(ctx, c, provider)
+}
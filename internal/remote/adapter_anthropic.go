@@ -0,0 +1,205 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("anthropic", func() ProviderAdapter { return anthropicAdapter{} })
+}
+
+// anthropicMaxTokens is the max_tokens value sent with every Anthropic
+// request. The Messages API requires it, but chatmock doesn't yet expose a
+// way for callers to tune it, so we use a generous fixed default rather
+// than guessing one from the request.
+const anthropicMaxTokens = 1024
+
+// anthropicAdapter speaks Anthropic's Messages API.
+type anthropicAdapter struct{}
+
+func (anthropicAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	endpoint, err := joinURL(provider.BaseURL, "/v1/messages")
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := anthropicPayload(req, model)
+	body, status, err := c.postJSON(ctx, provider, endpoint, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status >= 400 {
+		return body, status, nil
+	}
+
+	var anth anthropicMessage
+	if err := json.Unmarshal(body, &anth); err != nil {
+		return nil, 0, fmt.Errorf("decode anthropic response: %w", err)
+	}
+
+	resp := chat.CompletionResponse{
+		ID:      "chatcmpl-anthropic",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []chat.Choice{{Index: 0, FinishReason: anthropicFinishReason(anth.StopReason), Message: chat.Message{Role: "assistant", Content: anthropicText(anth.Content), ToolCalls: anthropicToolCalls(anth.Content)}}},
+		Usage:   chat.Usage{PromptTokens: anth.Usage.InputTokens, CompletionTokens: anth.Usage.OutputTokens, TotalTokens: anth.Usage.InputTokens + anth.Usage.OutputTokens},
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, http.StatusOK, nil
+}
+
+func (anthropicAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	url, err := joinURL(provider.BaseURL, "/v1/messages")
+	if err != nil {
+		return 0, err
+	}
+	payload := anthropicPayload(req, model)
+	payload["stream"] = true
+	return c.streamSSE(ctx, provider, url, payload, func(data []byte) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return false, nil
+		}
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Text != "" {
+				if err := onDelta(chat.ChunkDelta{Content: event.Delta.Text}); err != nil {
+					return true, err
+				}
+			}
+		case "message_stop":
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// Models lists models via Anthropic's GET /v1/models endpoint.
+func (anthropicAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	url, err := joinURL(provider.BaseURL, "/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := c.getJSON(ctx, provider, url)
+	if err != nil || status >= 400 {
+		return nil, err
+	}
+	var parsed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	out := make([]chat.ModelInfo, 0, len(parsed.Data))
+	for _, m := range parsed.Data {
+		out = append(out, chat.ModelInfo{ID: provider.ModelPrefix + m.ID, Object: "model", OwnedBy: provider.Kind})
+	}
+	return out, nil
+}
+
+type anthropicMessage struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicContentBlock is one entry of a Messages API response's content
+// array. Text and tool_use blocks are the only ones chatmock translates;
+// Input carries the tool_use block's arguments as raw JSON so it can be
+// re-marshaled into the OpenAI-shaped ToolCallFunction.Arguments string.
+type anthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// anthropicPayload translates an OpenAI-shaped request into Anthropic's
+// Messages API body: system-role messages are pulled out into the
+// top-level "system" field, since Anthropic has no "system" role inside
+// messages[].
+func anthropicPayload(req chat.CompletionRequest, model string) map[string]any {
+	system, messages := splitAnthropicMessages(req.Messages)
+	payload := map[string]any{"model": model, "messages": messages, "max_tokens": anthropicMaxTokens}
+	if system != "" {
+		payload["system"] = system
+	}
+	if req.Temperature != 0 {
+		payload["temperature"] = req.Temperature
+	}
+	return payload
+}
+
+func splitAnthropicMessages(messages []chat.Message) (string, []map[string]any) {
+	var system []string
+	out := make([]map[string]any, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		out = append(out, map[string]any{"role": m.Role, "content": m.Content})
+	}
+	return strings.Join(system, "\n"), out
+}
+
+func anthropicText(blocks []anthropicContentBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type == "text" && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// anthropicToolCalls translates a Messages API response's tool_use content
+// blocks into the OpenAI-shaped ToolCall the rest of chatmock expects.
+func anthropicToolCalls(blocks []anthropicContentBlock) []chat.ToolCall {
+	var calls []chat.ToolCall
+	for _, b := range blocks {
+		if b.Type != "tool_use" {
+			continue
+		}
+		input := b.Input
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		calls = append(calls, chat.ToolCall{ID: b.ID, Type: "function", Function: chat.ToolCallFunction{Name: b.Name, Arguments: string(input)}})
+	}
+	return calls
+}
+
+// anthropicFinishReason maps Anthropic's stop_reason onto the OpenAI
+// finish_reason values chatmock's response schema uses.
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
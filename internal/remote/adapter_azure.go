@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("azure", func() ProviderAdapter { return azureAdapter{} })
+}
+
+// azureAdapter speaks Azure OpenAI's deployment-scoped wire format: the
+// model is a deployment name in the URL path rather than a body field, the
+// API version is a required "api-version" query parameter, and requests
+// authenticate with an "api-key" header instead of a Bearer token (handled
+// in applyAuthHeaders).
+type azureAdapter struct{}
+
+func (azureAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	endpoint, err := azureURL(provider, model, "chat/completions")
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := map[string]any{"messages": req.Messages}
+	if req.Temperature != 0 {
+		payload["temperature"] = req.Temperature
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	return c.postJSON(ctx, provider, endpoint, payload)
+}
+
+func (azureAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	endpoint, err := azureURL(provider, model, "chat/completions")
+	if err != nil {
+		return 0, err
+	}
+	payload := map[string]any{"messages": req.Messages, "stream": true}
+	if req.Temperature != 0 {
+		payload["temperature"] = req.Temperature
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	acc := newToolCallAccumulator()
+	return c.streamSSE(ctx, provider, endpoint, payload, func(data []byte) (bool, error) {
+		if string(data) == "[DONE]" {
+			if err := acc.flush(onDelta); err != nil {
+				return true, err
+			}
+			return true, nil
+		}
+		var chunk chat.CompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, nil
+		}
+		if len(chunk.Choices) == 0 {
+			return false, nil
+		}
+		delta := chunk.Choices[0].Delta
+		acc.add(delta.ToolCalls)
+		if delta.Content != "" {
+			if err := onDelta(chat.ChunkDelta{Content: delta.Content}); err != nil {
+				return true, err
+			}
+		}
+		if chunk.Choices[0].FinishReason != nil {
+			if err := acc.flush(onDelta); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// Models lists the deployments available on an Azure OpenAI resource via
+// its GET /openai/models endpoint.
+func (azureAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	url, err := joinURL(provider.BaseURL, "/openai/models")
+	if err != nil {
+		return nil, err
+	}
+	url, err = withQueryParam(url, "api-version", provider.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := c.getJSON(ctx, provider, url)
+	if err != nil || status >= 400 {
+		return nil, err
+	}
+	var parsed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	out := make([]chat.ModelInfo, 0, len(parsed.Data))
+	for _, m := range parsed.Data {
+		out = append(out, chat.ModelInfo{ID: provider.ModelPrefix + m.ID, Object: "model", OwnedBy: provider.Kind})
+	}
+	return out, nil
+}
+
+// azureURL builds {baseURL}/openai/deployments/{model}/{action} with the
+// required "api-version" query parameter appended, per Azure OpenAI's REST
+// contract (https://learn.microsoft.com/azure/ai-services/openai).
+func azureURL(provider Provider, model, action string) (string, error) {
+	endpoint, err := joinURL(provider.BaseURL, fmt.Sprintf("/openai/deployments/%s/%s", model, action))
+	if err != nil {
+		return "", err
+	}
+	return withQueryParam(endpoint, "api-version", provider.APIVersion)
+}
@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("chatgpt", func() ProviderAdapter { return chatgptAdapter{} })
+}
+
+// chatgptAdapter speaks the ChatGPT backend's Responses API, translating
+// its output[] shape back into the unified chat.CompletionResponse format.
+type chatgptAdapter struct{}
+
+func (chatgptAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	url, err := joinURL(provider.BaseURL, "/backend-api/codex/responses")
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := chatgptPayload(req, model)
+
+	body, status, err := c.postJSON(ctx, provider, url, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status >= 400 {
+		return body, status, nil
+	}
+
+	text := extractChatGPTOutputText(body)
+	toolCalls := extractChatGPTToolCalls(body)
+	finishReason := "stop"
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	resp := chat.CompletionResponse{
+		ID:      "chatcmpl-chatgpt",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []chat.Choice{{
+			Index:        0,
+			FinishReason: finishReason,
+			Message:      chat.Message{Role: "assistant", Content: text, ToolCalls: toolCalls},
+		}},
+		Usage: chat.Usage{},
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, http.StatusOK, nil
+}
+
+func (chatgptAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	url, err := joinURL(provider.BaseURL, "/backend-api/codex/responses")
+	if err != nil {
+		return 0, err
+	}
+	payload := map[string]any{"model": model, "input": chatgptInput(req.Messages), "stream": true}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	return c.streamSSE(ctx, provider, url, payload, func(data []byte) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+			Item  struct {
+				Type      string `json:"type"`
+				CallID    string `json:"call_id"`
+				ID        string `json:"id"`
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			} `json:"item"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return false, nil
+		}
+		switch event.Type {
+		case "response.output_text.delta":
+			if event.Delta != "" {
+				if err := onDelta(chat.ChunkDelta{Content: event.Delta}); err != nil {
+					return true, err
+				}
+			}
+		case "response.output_item.done":
+			if event.Item.Type == "function_call" {
+				id := event.Item.CallID
+				if id == "" {
+					id = event.Item.ID
+				}
+				call := chat.ToolCall{ID: id, Type: "function", Function: chat.ToolCallFunction{Name: event.Item.Name, Arguments: event.Item.Arguments}}
+				if err := onDelta(chat.ChunkDelta{ToolCalls: []chat.ToolCall{call}}); err != nil {
+					return true, err
+				}
+			}
+		case "response.completed":
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// Models is a placeholder: the ChatGPT backend API doesn't expose a model
+// listing endpoint, so callers fall back to the prefix/* placeholder.
+func (chatgptAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	return nil, nil
+}
+
+func chatgptPayload(req chat.CompletionRequest, model string) map[string]any {
+	payload := map[string]any{"model": model, "input": chatgptInput(req.Messages), "stream": false}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	return payload
+}
+
+func chatgptInput(messages []chat.Message) []map[string]any {
+	input := make([]map[string]any, 0, len(messages))
+	for _, m := range messages {
+		input = append(input, map[string]any{
+			"role":    m.Role,
+			"content": []map[string]any{{"type": "input_text", "text": m.Content}},
+		})
+	}
+	return input
+}
+
+// extractChatGPTToolCalls pulls function_call items out of the ChatGPT
+// backend's output[] array and translates them into the unified
+// chat.ToolCall shape.
+func extractChatGPTToolCalls(body []byte) []chat.ToolCall {
+	var raw map[string]any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+	out, ok := raw["output"].([]any)
+	if !ok {
+		return nil
+	}
+	var calls []chat.ToolCall
+	for _, item := range out {
+		obj, _ := item.(map[string]any)
+		if obj["type"] != "function_call" {
+			continue
+		}
+		id, _ := obj["call_id"].(string)
+		if id == "" {
+			id, _ = obj["id"].(string)
+		}
+		name, _ := obj["name"].(string)
+		arguments, _ := obj["arguments"].(string)
+		calls = append(calls, chat.ToolCall{ID: id, Type: "function", Function: chat.ToolCallFunction{Name: name, Arguments: arguments}})
+	}
+	return calls
+}
+
+func extractChatGPTOutputText(body []byte) string {
+	var simple struct {
+		OutputText string `json:"output_text"`
+	}
+	if err := json.Unmarshal(body, &simple); err == nil && strings.TrimSpace(simple.OutputText) != "" {
+		return simple.OutputText
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return ""
+	}
+	if v, ok := raw["output_text"].(string); ok {
+		return v
+	}
+	if out, ok := raw["output"].([]any); ok {
+		chunks := make([]string, 0)
+		for _, item := range out {
+			obj, _ := item.(map[string]any)
+			content, _ := obj["content"].([]any)
+			for _, c := range content {
+				co, _ := c.(map[string]any)
+				if t, ok := co["text"].(string); ok {
+					chunks = append(chunks, t)
+				}
+			}
+		}
+		return strings.TrimSpace(strings.Join(chunks, "\n"))
+	}
+	return ""
+}
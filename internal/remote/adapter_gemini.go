@@ -0,0 +1,207 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("gemini", func() ProviderAdapter { return geminiAdapter{} })
+}
+
+// geminiAdapter speaks Google Gemini's generateContent API.
+type geminiAdapter struct{}
+
+func (geminiAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	endpoint, err := geminiURL(provider, model, "generateContent")
+	if err != nil {
+		return nil, 0, err
+	}
+	body, status, err := c.postJSON(ctx, provider, endpoint, geminiPayload(req))
+	if err != nil {
+		return nil, 0, err
+	}
+	if status >= 400 {
+		return body, status, nil
+	}
+
+	var gem geminiResponse
+	if err := json.Unmarshal(body, &gem); err != nil {
+		return nil, 0, fmt.Errorf("decode gemini response: %w", err)
+	}
+
+	finish := "stop"
+	if len(gem.Candidates) > 0 && gem.Candidates[0].FinishReason == "MAX_TOKENS" {
+		finish = "length"
+	}
+	resp := chat.CompletionResponse{
+		ID:      "chatcmpl-gemini",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []chat.Choice{{Index: 0, FinishReason: finish, Message: chat.Message{Role: "assistant", Content: geminiText(gem)}}},
+		Usage:   chat.Usage{PromptTokens: gem.UsageMetadata.PromptTokenCount, CompletionTokens: gem.UsageMetadata.CandidatesTokenCount, TotalTokens: gem.UsageMetadata.TotalTokenCount},
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, http.StatusOK, nil
+}
+
+// StreamChatCompletions consumes Gemini's streamGenerateContent response, which is
+// a single streamed JSON array rather than SSE or NDJSON, by decoding each
+// array element as it arrives instead of waiting for the whole body.
+func (geminiAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	url, err := geminiURL(provider, model, "streamGenerateContent")
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(geminiPayload(req))
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	applyAuthHeaders(httpReq, provider)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	if _, err := decoder.Token(); err != nil {
+		return resp.StatusCode, err
+	}
+	for decoder.More() {
+		var chunk geminiResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			return resp.StatusCode, err
+		}
+		if text := geminiText(chunk); text != "" {
+			if err := onDelta(chat.ChunkDelta{Content: text}); err != nil {
+				return resp.StatusCode, err
+			}
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Models lists models via Gemini's GET /v1beta/models ListModels endpoint,
+// stripping the "models/" resource-name prefix off each entry's name.
+func (geminiAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	endpoint, err := joinURL(provider.BaseURL, "/v1beta/models")
+	if err != nil {
+		return nil, err
+	}
+	if key := strings.TrimSpace(provider.APIKey); key != "" {
+		endpoint, err = withQueryParam(endpoint, "key", key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, status, err := c.getJSON(ctx, provider, endpoint)
+	if err != nil || status >= 400 {
+		return nil, err
+	}
+	var parsed struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	out := make([]chat.ModelInfo, 0, len(parsed.Models))
+	for _, m := range parsed.Models {
+		out = append(out, chat.ModelInfo{ID: provider.ModelPrefix + strings.TrimPrefix(m.Name, "models/"), Object: "model", OwnedBy: provider.Kind})
+	}
+	return out, nil
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func geminiText(gem geminiResponse) string {
+	if len(gem.Candidates) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(gem.Candidates[0].Content.Parts))
+	for _, p := range gem.Candidates[0].Content.Parts {
+		parts = append(parts, p.Text)
+	}
+	return strings.Join(parts, "")
+}
+
+// geminiPayload translates an OpenAI-shaped request into Gemini's
+// generateContent body: assistant/user roles become model/user, and any
+// system-role messages move into systemInstruction since Gemini has no
+// "system" role inside contents[].
+func geminiPayload(req chat.CompletionRequest) map[string]any {
+	var systemParts []map[string]any
+	contents := make([]map[string]any, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			systemParts = append(systemParts, map[string]any{"text": m.Content})
+			continue
+		}
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]any{"role": role, "parts": []map[string]any{{"text": m.Content}}})
+	}
+	payload := map[string]any{"contents": contents}
+	if len(systemParts) > 0 {
+		payload["systemInstruction"] = map[string]any{"parts": systemParts}
+	}
+	if req.Temperature != 0 {
+		payload["generationConfig"] = map[string]any{"temperature": req.Temperature}
+	}
+	return payload
+}
+
+// geminiURL builds the {model}:{action} endpoint Gemini expects and
+// appends the API key as a "?key=" query parameter, since Gemini
+// authenticates requests that way instead of an Authorization header.
+func geminiURL(provider Provider, model, action string) (string, error) {
+	endpoint, err := joinURL(provider.BaseURL, fmt.Sprintf("/v1beta/models/%s:%s", model, action))
+	if err != nil {
+		return "", err
+	}
+	if key := strings.TrimSpace(provider.APIKey); key != "" {
+		endpoint, err = withQueryParam(endpoint, "key", key)
+		if err != nil {
+			return "", err
+		}
+	}
+	return endpoint, nil
+}
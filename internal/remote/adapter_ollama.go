@@ -0,0 +1,177 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("ollama", func() ProviderAdapter { return ollamaAdapter{} })
+}
+
+// ollamaAdapter speaks Ollama's native /api/chat wire format.
+type ollamaAdapter struct{}
+
+func (ollamaAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	url, err := joinURL(provider.BaseURL, "/api/chat")
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := map[string]any{"model": model, "messages": req.Messages, "stream": false}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	body, status, err := c.postJSON(ctx, provider, url, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status >= 400 {
+		return body, status, nil
+	}
+
+	var ollamaResp struct {
+		Model   string `json:"model"`
+		Message struct {
+			Role      string `json:"role"`
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string         `json:"name"`
+					Arguments map[string]any `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, 0, fmt.Errorf("decode ollama response: %w", err)
+	}
+
+	toolCalls := make([]chat.ToolCall, 0, len(ollamaResp.Message.ToolCalls))
+	for i, tc := range ollamaResp.Message.ToolCalls {
+		arguments, _ := json.Marshal(tc.Function.Arguments)
+		toolCalls = append(toolCalls, chat.ToolCall{ID: fmt.Sprintf("call_%d", i), Type: "function", Function: chat.ToolCallFunction{Name: tc.Function.Name, Arguments: string(arguments)}})
+	}
+	finishReason := "stop"
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	resp := chat.CompletionResponse{
+		ID:      "chatcmpl-ollama",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   ollamaResp.Model,
+		Choices: []chat.Choice{{Index: 0, FinishReason: finishReason, Message: chat.Message{Role: ollamaResp.Message.Role, Content: ollamaResp.Message.Content, ToolCalls: toolCalls}}},
+		Usage:   chat.Usage{PromptTokens: ollamaResp.PromptEvalCount, CompletionTokens: ollamaResp.EvalCount},
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, http.StatusOK, nil
+}
+
+func (ollamaAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	url, err := joinURL(provider.BaseURL, "/api/chat")
+	if err != nil {
+		return 0, err
+	}
+	payload := map[string]any{"model": model, "messages": req.Messages, "stream": true}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	applyAuthHeaders(httpReq, provider)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var chunk struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string         `json:"name"`
+						Arguments map[string]any `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			Done bool `json:"done"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue
+		}
+		toolCalls := make([]chat.ToolCall, 0, len(chunk.Message.ToolCalls))
+		for i, tc := range chunk.Message.ToolCalls {
+			arguments, _ := json.Marshal(tc.Function.Arguments)
+			toolCalls = append(toolCalls, chat.ToolCall{ID: fmt.Sprintf("call_%d", i), Type: "function", Function: chat.ToolCallFunction{Name: tc.Function.Name, Arguments: string(arguments)}})
+		}
+		if chunk.Message.Content != "" || len(toolCalls) > 0 {
+			if err := onDelta(chat.ChunkDelta{Content: chunk.Message.Content, ToolCalls: toolCalls}); err != nil {
+				return resp.StatusCode, err
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	return resp.StatusCode, scanner.Err()
+}
+
+// Models lists locally pulled models via Ollama's native /api/tags
+// endpoint.
+func (ollamaAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	url, err := joinURL(provider.BaseURL, "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := c.getJSON(ctx, provider, url)
+	if err != nil || status >= 400 {
+		return nil, err
+	}
+	var parsed struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	out := make([]chat.ModelInfo, 0, len(parsed.Models))
+	for _, m := range parsed.Models {
+		out = append(out, chat.ModelInfo{ID: provider.ModelPrefix + m.Name, Object: "model", OwnedBy: provider.Kind})
+	}
+	return out, nil
+}
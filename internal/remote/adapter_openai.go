@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+
+	"chatmock/internal/chat"
+)
+
+func init() {
+	Register("openai", func() ProviderAdapter { return openAIAdapter{} })
+	Register("codex", func() ProviderAdapter { return openAIAdapter{} })
+}
+
+// openAIAdapter speaks the OpenAI-compatible /v1/chat/completions wire
+// format used by chatmock's "openai" and "codex" provider kinds, and by any
+// other OpenAI-compatible server.
+type openAIAdapter struct{}
+
+func (openAIAdapter) ChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
+	url, err := joinURL(provider.BaseURL, "/v1/chat/completions")
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := map[string]any{"model": model, "messages": req.Messages}
+	if req.Temperature != 0 {
+		payload["temperature"] = req.Temperature
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	return c.postJSON(ctx, provider, url, payload)
+}
+
+func (openAIAdapter) StreamChatCompletions(ctx context.Context, c *Client, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	url, err := joinURL(provider.BaseURL, "/v1/chat/completions")
+	if err != nil {
+		return 0, err
+	}
+	payload := map[string]any{"model": model, "messages": req.Messages, "stream": true}
+	if req.Temperature != 0 {
+		payload["temperature"] = req.Temperature
+	}
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	acc := newToolCallAccumulator()
+	return c.streamSSE(ctx, provider, url, payload, func(data []byte) (bool, error) {
+		if string(data) == "[DONE]" {
+			if err := acc.flush(onDelta); err != nil {
+				return true, err
+			}
+			return true, nil
+		}
+		var chunk chat.CompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, nil
+		}
+		if len(chunk.Choices) == 0 {
+			return false, nil
+		}
+		delta := chunk.Choices[0].Delta
+		acc.add(delta.ToolCalls)
+		if delta.Content != "" {
+			if err := onDelta(chat.ChunkDelta{Content: delta.Content}); err != nil {
+				return true, err
+			}
+		}
+		if chunk.Choices[0].FinishReason != nil {
+			if err := acc.flush(onDelta); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// Models lists the models an OpenAI-compatible server serves via its
+// standard GET /v1/models endpoint.
+func (openAIAdapter) Models(ctx context.Context, c *Client, provider Provider) ([]chat.ModelInfo, error) {
+	url, err := joinURL(provider.BaseURL, "/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := c.getJSON(ctx, provider, url)
+	if err != nil || status >= 400 {
+		return nil, err
+	}
+	var parsed struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	out := make([]chat.ModelInfo, 0, len(parsed.Data))
+	for _, m := range parsed.Data {
+		out = append(out, chat.ModelInfo{ID: provider.ModelPrefix + m.ID, Object: "model", Created: m.Created, OwnedBy: provider.Kind})
+	}
+	return out, nil
+}
@@ -4,87 +4,258 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"chatmock/internal/chat"
+	"chatmock/internal/storage"
 )
 
+// providersStorageKey is the Store key Manager persists its providers
+// under when AttachStorage is used.
+const providersStorageKey = "providers"
+
 type Provider struct {
-	Name        string `json:"name"`
-	Kind        string `json:"kind"`
-	BaseURL     string `json:"base_url"`
-	APIKey      string `json:"api_key,omitempty"`
-	AccessToken string `json:"access_token,omitempty"`
-	AccountID   string `json:"account_id,omitempty"`
-	ModelPrefix string `json:"model_prefix"`
-	RouteAll    bool   `json:"route_all"`
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"`
+	BaseURL     string      `json:"base_url"`
+	APIKey      string      `json:"api_key,omitempty"`
+	AccessToken string      `json:"access_token,omitempty"`
+	AccountID   string      `json:"account_id,omitempty"`
+	ModelPrefix string      `json:"model_prefix"`
+	RouteAll    bool        `json:"route_all"`
+	RetryPolicy RetryPolicy `json:"retry_policy,omitempty"`
+	// Priority groups providers sharing a ModelPrefix into failover tiers:
+	// lower values are tried first, and a tier is only consulted once every
+	// provider in the tier ahead of it is unhealthy or exhausted.
+	Priority int `json:"priority,omitempty"`
+	// Weight controls how often this provider is picked relative to its
+	// same-priority peers, via weighted random selection (the same idea
+	// behind nginx's smooth weighted round-robin). Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+	// APIVersion is the "api-version" query parameter Azure OpenAI requires
+	// on every request. Ignored by every other kind.
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// azureDefaultAPIVersion is used when an "azure" provider doesn't specify
+// its own APIVersion.
+const azureDefaultAPIVersion = "2024-06-01"
+
+// RetryPolicy configures how Client.postJSON retries a single provider
+// before giving up, and the circuit-breaker thresholds Manager applies to
+// that provider. Zero-valued fields fall back to package defaults (see
+// normalizeRetryPolicy), so a provider posted without one behaves exactly
+// like the previous hardcoded single-attempt, 3-failure breaker.
+type RetryPolicy struct {
+	MaxAttempts      int   `json:"max_attempts,omitempty"`
+	BaseBackoffMS    int   `json:"base_backoff_ms,omitempty"`
+	MaxBackoffMS     int   `json:"max_backoff_ms,omitempty"`
+	RetryOnStatus    []int `json:"retry_on_status,omitempty"`
+	TimeoutMS        int   `json:"timeout_ms,omitempty"`
+	FailureThreshold int   `json:"failure_threshold,omitempty"`
+	CooldownMS       int   `json:"cooldown_ms,omitempty"`
+}
+
+func (p RetryPolicy) baseBackoff() time.Duration {
+	return time.Duration(p.BaseBackoffMS) * time.Millisecond
+}
+func (p RetryPolicy) maxBackoff() time.Duration {
+	return time.Duration(p.MaxBackoffMS) * time.Millisecond
+}
+func (p RetryPolicy) timeout() time.Duration  { return time.Duration(p.TimeoutMS) * time.Millisecond }
+func (p RetryPolicy) cooldown() time.Duration { return time.Duration(p.CooldownMS) * time.Millisecond }
+
+func (p RetryPolicy) shouldRetryStatus(status int) bool {
+	for _, s := range p.RetryOnStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRetryPolicy fills in every unset field with the package's
+// historical defaults: one attempt (no same-provider retry), a 100ms-2s
+// backoff window, retrying 429, 500, 502, 503 and 504, and the breaker's original
+// 3-failure/30s-cooldown thresholds.
+func normalizeRetryPolicy(p RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseBackoffMS <= 0 {
+		p.BaseBackoffMS = 100
+	}
+	if p.MaxBackoffMS <= 0 {
+		p.MaxBackoffMS = 2000
+	}
+	if len(p.RetryOnStatus) == 0 {
+		p.RetryOnStatus = []int{http.StatusTooManyRequests, 500, 502, 503, 504}
+	}
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = failureThreshold
+	}
+	if p.CooldownMS <= 0 {
+		p.CooldownMS = int(openCooldown / time.Millisecond)
+	}
+	return p
 }
 
 type ProviderView struct {
-	Name           string `json:"name"`
-	Kind           string `json:"kind"`
-	BaseURL        string `json:"base_url"`
-	HasAPIKey      bool   `json:"has_api_key"`
-	HasAccessToken bool   `json:"has_access_token"`
-	HasAccountID   bool   `json:"has_account_id"`
-	ModelPrefix    string `json:"model_prefix"`
-	RouteAll       bool   `json:"route_all"`
+	Name                string       `json:"name"`
+	Kind                string       `json:"kind"`
+	BaseURL             string       `json:"base_url"`
+	HasAPIKey           bool         `json:"has_api_key"`
+	HasAccessToken      bool         `json:"has_access_token"`
+	HasAccountID        bool         `json:"has_account_id"`
+	ModelPrefix         string       `json:"model_prefix"`
+	RouteAll            bool         `json:"route_all"`
+	RetryPolicy         RetryPolicy  `json:"retry_policy"`
+	BreakerState        BreakerState `json:"breaker_state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
 }
 
 type Manager struct {
-	mu        sync.RWMutex
-	providers []Provider
+	mu            sync.RWMutex
+	providers     []Provider
+	healthTracker *HealthTracker
+	storage       storage.Store
 }
 
 func NewManager(seed []Provider) *Manager {
-	m := &Manager{}
+	m := &Manager{healthTracker: NewHealthTracker()}
 	for _, p := range seed {
 		m.Upsert(p)
 	}
 	return m
 }
 
+// AttachStorage loads any providers previously persisted in store into m,
+// then persists every subsequent Upsert/SetAll to store so state survives
+// a restart. Call it once, right after NewManager.
+func (m *Manager) AttachStorage(store storage.Store) error {
+	m.mu.Lock()
+	m.storage = store
+	m.mu.Unlock()
+	return m.ReloadFromStorage()
+}
+
+// ReloadFromStorage re-reads providers from the store attached via
+// AttachStorage and replaces the manager's current providers with them.
+// It is a no-op if no storage is attached or nothing has been persisted
+// yet; callers use it to pick up out-of-band edits (see storage.Watcher).
+func (m *Manager) ReloadFromStorage() error {
+	m.mu.RLock()
+	store := m.storage
+	m.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	var providers []Provider
+	if err := storage.LoadJSON(store, providersStorageKey, &providers); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	m.setAll(providers, false)
+	return nil
+}
+
+// Upsert adds or replaces a provider by name and resets its circuit
+// breaker, so re-submitting a provider (e.g. with a corrected API key) is
+// the documented way to clear an unauthorized or open breaker state.
 func (m *Manager) Upsert(provider Provider) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	p := normalizeProvider(provider)
+	m.healthTracker.Reset(p.Name)
+	m.healthTracker.Configure(p.Name, p.RetryPolicy.FailureThreshold, p.RetryPolicy.cooldown())
+	replaced := false
 	for i := range m.providers {
 		if sameProvider(m.providers[i], p) {
 			m.providers[i] = p
-			return
+			replaced = true
+			break
 		}
 	}
-	m.providers = append(m.providers, p)
+	if !replaced {
+		m.providers = append(m.providers, p)
+	}
+	snapshot := append([]Provider(nil), m.providers...)
+	store := m.storage
+	m.mu.Unlock()
+	persistProviders(store, snapshot)
 }
 
 func (m *Manager) SetAll(providers []Provider) {
+	m.setAll(providers, true)
+}
+
+// setAll replaces the manager's providers, optionally persisting the
+// result; shouldPersist is false when called from ReloadFromStorage so a reload
+// doesn't immediately re-save the document it just loaded.
+func (m *Manager) setAll(providers []Provider, shouldPersist bool) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	m.providers = m.providers[:0]
 	for _, p := range providers {
-		m.providers = append(m.providers, normalizeProvider(p))
+		norm := normalizeProvider(p)
+		m.healthTracker.Reset(norm.Name)
+		m.healthTracker.Configure(norm.Name, norm.RetryPolicy.FailureThreshold, norm.RetryPolicy.cooldown())
+		m.providers = append(m.providers, norm)
 	}
+	snapshot := append([]Provider(nil), m.providers...)
+	store := m.storage
+	m.mu.Unlock()
+	if shouldPersist {
+		persistProviders(store, snapshot)
+	}
+}
+
+// persistProviders saves providers to store, silently doing nothing when
+// store is nil (no storage attached) or the save fails; persistence is
+// best-effort so a disk hiccup never fails the in-memory update that
+// triggered it.
+func persistProviders(store storage.Store, providers []Provider) {
+	if store == nil {
+		return
+	}
+	_ = storage.SaveJSON(store, providersStorageKey, providers)
+}
+
+// Health returns the current circuit-breaker snapshot for every known
+// provider, keyed by provider name.
+func (m *Manager) Health() map[string]Health {
+	return m.healthTracker.Snapshot()
 }
 
 func (m *Manager) Views() []ProviderView {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	health := m.healthTracker.Snapshot()
 	out := make([]ProviderView, 0, len(m.providers))
 	for _, p := range m.providers {
+		h, ok := health[p.Name]
+		if !ok {
+			h.State = StateClosed
+		}
 		out = append(out, ProviderView{
-			Name:           p.Name,
-			Kind:           p.Kind,
-			BaseURL:        p.BaseURL,
-			HasAPIKey:      strings.TrimSpace(p.APIKey) != "",
-			HasAccessToken: strings.TrimSpace(p.AccessToken) != "",
-			HasAccountID:   strings.TrimSpace(p.AccountID) != "",
-			ModelPrefix:    p.ModelPrefix,
-			RouteAll:       p.RouteAll,
+			Name:                p.Name,
+			Kind:                p.Kind,
+			BaseURL:             p.BaseURL,
+			HasAPIKey:           strings.TrimSpace(p.APIKey) != "",
+			HasAccessToken:      strings.TrimSpace(p.AccessToken) != "",
+			HasAccountID:        strings.TrimSpace(p.AccountID) != "",
+			ModelPrefix:         p.ModelPrefix,
+			RouteAll:            p.RouteAll,
+			RetryPolicy:         p.RetryPolicy,
+			BreakerState:        h.State,
+			ConsecutiveFailures: h.ConsecutiveFailures,
 		})
 	}
 	return out
@@ -98,12 +269,14 @@ func (m *Manager) Providers() []Provider {
 	return out
 }
 
+// Match returns the highest-priority healthy provider matching
+// requestedModel, breaking ties between same-priority peers by weighted
+// random selection. It does not fail over; callers that want
+// retry-with-failover should use DispatchChatCompletion.
 func (m *Manager) Match(requestedModel string) (Provider, string, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	for _, p := range m.providers {
-		if ShouldProxy(p, requestedModel) {
-			return p, NormalizeModel(p, requestedModel), true
+	for _, c := range m.Candidates(requestedModel) {
+		if m.healthTracker.Available(c.Provider.Name) {
+			return c.Provider, NormalizeModel(c.Provider, requestedModel), true
 		}
 	}
 	return Provider{}, "", false
@@ -132,6 +305,12 @@ func normalizeProvider(p Provider) Provider {
 			p.ModelPrefix = "codex/"
 		case "chatgpt":
 			p.ModelPrefix = "chatgpt/"
+		case "anthropic":
+			p.ModelPrefix = "anthropic/"
+		case "gemini":
+			p.ModelPrefix = "gemini/"
+		case "azure":
+			p.ModelPrefix = "azure/"
 		default:
 			p.ModelPrefix = "remote/"
 		}
@@ -139,8 +318,22 @@ func normalizeProvider(p Provider) Provider {
 	if strings.TrimSpace(p.Name) == "" {
 		p.Name = strings.TrimSuffix(p.ModelPrefix, "/")
 	}
-	if strings.TrimSpace(p.BaseURL) == "" && p.Kind == "chatgpt" {
-		p.BaseURL = "https://chatgpt.com"
+	if strings.TrimSpace(p.BaseURL) == "" {
+		switch p.Kind {
+		case "chatgpt":
+			p.BaseURL = "https://chatgpt.com"
+		case "anthropic":
+			p.BaseURL = "https://api.anthropic.com"
+		case "gemini":
+			p.BaseURL = "https://generativelanguage.googleapis.com"
+		}
+	}
+	if p.Kind == "azure" && strings.TrimSpace(p.APIVersion) == "" {
+		p.APIVersion = azureDefaultAPIVersion
+	}
+	p.RetryPolicy = normalizeRetryPolicy(p.RetryPolicy)
+	if p.Weight <= 0 {
+		p.Weight = 1
 	}
 	return p
 }
@@ -153,161 +346,124 @@ func NewClient() *Client {
 	return &Client{httpClient: &http.Client{Timeout: 60 * time.Second}}
 }
 
-func (c *Client) ChatCompletions(ctx context.Context, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
-	switch provider.Kind {
-	case "ollama":
-		return c.chatOllama(ctx, provider, req, model)
-	case "chatgpt":
-		return c.chatChatGPT(ctx, provider, req, model)
-	case "codex", "openai":
-		fallthrough
-	default:
-		return c.chatOpenAICompatible(ctx, provider, req, model)
-	}
-}
-
-func (c *Client) chatOpenAICompatible(ctx context.Context, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
-	url, err := joinURL(provider.BaseURL, "/v1/chat/completions")
+// postJSON sends a single logical request to a provider, retrying against
+// that same provider (not failing over) up to RetryPolicy.MaxAttempts times
+// on a retryable status or network error. It honors an upstream
+// "Retry-After" header when present, otherwise backs off exponentially with
+// jitter, and applies RetryPolicy.TimeoutMS as a per-attempt deadline when
+// set.
+func (c *Client) postJSON(ctx context.Context, provider Provider, targetURL string, payload any) ([]byte, int, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, 0, err
 	}
-	payload := map[string]any{"model": model, "messages": req.Messages}
-	if req.Temperature != 0 {
-		payload["temperature"] = req.Temperature
+	policy := provider.RetryPolicy
+
+	var respBody []byte
+	var status int
+	var retryAfter time.Duration
+	var reqErr error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		respBody, status, retryAfter, reqErr = c.doPostJSON(ctx, provider, targetURL, body, policy.timeout())
+		if reqErr == nil && !policy.shouldRetryStatus(status) {
+			return respBody, status, nil
+		}
+		if reqErr != nil && ctx.Err() != nil {
+			return respBody, status, reqErr
+		}
+		if attempt == policy.MaxAttempts-1 {
+			break
+		}
+		delay := backoffDelay(policy.baseBackoff(), policy.maxBackoff(), attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if !waitFor(ctx, delay) {
+			return respBody, status, ctx.Err()
+		}
 	}
-	return c.postJSON(ctx, provider, url, payload)
+	return respBody, status, reqErr
 }
 
-func (c *Client) chatChatGPT(ctx context.Context, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
-	url, err := joinURL(provider.BaseURL, "/backend-api/codex/responses")
+// doPostJSON performs one HTTP attempt, applying timeout as a per-attempt
+// deadline on top of ctx when positive, and parses a "Retry-After" header
+// (seconds, the only form these mock upstreams send) off the response.
+func (c *Client) doPostJSON(ctx context.Context, provider Provider, targetURL string, body []byte, timeout time.Duration) ([]byte, int, time.Duration, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
-	input := make([]map[string]any, 0, len(req.Messages))
-	for _, m := range req.Messages {
-		input = append(input, map[string]any{
-			"role":    m.Role,
-			"content": []map[string]any{{"type": "input_text", "text": m.Content}},
-		})
-	}
-	payload := map[string]any{"model": model, "input": input, "stream": false}
+	httpReq.Header.Set("Content-Type", "application/json")
+	applyAuthHeaders(httpReq, provider)
 
-	body, status, err := c.postJSON(ctx, provider, url, payload)
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, 0, err
-	}
-	if status >= 400 {
-		return body, status, nil
-	}
-
-	text := extractChatGPTOutputText(body)
-	if text == "" {
-		text = ""
+		return nil, 0, 0, err
 	}
-	resp := chat.CompletionResponse{
-		ID:      "chatcmpl-chatgpt",
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   model,
-		Choices: []chat.Choice{{
-			Index:        0,
-			FinishReason: "stop",
-			Message:      chat.Message{Role: "assistant", Content: text},
-		}},
-		Usage: chat.Usage{},
-	}
-	out, err := json.Marshal(resp)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, err
+		return nil, resp.StatusCode, 0, err
 	}
-	return out, http.StatusOK, nil
+	return respBody, resp.StatusCode, retryAfterDuration(resp.Header.Get("Retry-After")), nil
 }
 
-func extractChatGPTOutputText(body []byte) string {
-	var simple struct {
-		OutputText string `json:"output_text"`
-	}
-	if err := json.Unmarshal(body, &simple); err == nil && strings.TrimSpace(simple.OutputText) != "" {
-		return simple.OutputText
-	}
-
-	var raw map[string]any
-	if err := json.Unmarshal(body, &raw); err != nil {
-		return ""
-	}
-	if v, ok := raw["output_text"].(string); ok {
-		return v
-	}
-	if out, ok := raw["output"].([]any); ok {
-		chunks := make([]string, 0)
-		for _, item := range out {
-			obj, _ := item.(map[string]any)
-			content, _ := obj["content"].([]any)
-			for _, c := range content {
-				co, _ := c.(map[string]any)
-				if t, ok := co["text"].(string); ok {
-					chunks = append(chunks, t)
-				}
-			}
-		}
-		return strings.TrimSpace(strings.Join(chunks, "\n"))
-	}
-	return ""
+// retryAfterDuration parses the seconds form of a Retry-After header,
+// returning zero if it's absent or not a plain integer.
+func retryAfterDuration(header string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
 
-func (c *Client) chatOllama(ctx context.Context, provider Provider, req chat.CompletionRequest, model string) ([]byte, int, error) {
-	url, err := joinURL(provider.BaseURL, "/api/chat")
+// getJSON issues a single GET request with the provider's auth headers
+// applied. Unlike postJSON it never retries: callers use it for
+// best-effort reads like model listings, where a transient failure should
+// just fall back to whatever placeholder the caller already has.
+func (c *Client) getJSON(ctx context.Context, provider Provider, targetURL string) ([]byte, int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
 	if err != nil {
 		return nil, 0, err
 	}
-	payload := map[string]any{"model": model, "messages": req.Messages, "stream": false}
-	body, status, err := c.postJSON(ctx, provider, url, payload)
+	applyAuthHeaders(httpReq, provider)
+
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, 0, err
 	}
-	if status >= 400 {
-		return body, status, nil
-	}
-
-	var ollamaResp struct {
-		Model   string `json:"model"`
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		PromptEvalCount int `json:"prompt_eval_count"`
-		EvalCount       int `json:"eval_count"`
-	}
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return nil, 0, fmt.Errorf("decode ollama response: %w", err)
-	}
-
-	resp := chat.CompletionResponse{
-		ID:      "chatcmpl-ollama",
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   ollamaResp.Model,
-		Choices: []chat.Choice{{Index: 0, FinishReason: "stop", Message: chat.Message{Role: ollamaResp.Message.Role, Content: ollamaResp.Message.Content}}},
-		Usage:   chat.Usage{PromptTokens: ollamaResp.PromptEvalCount, CompletionTokens: ollamaResp.EvalCount},
-	}
-	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
-	out, err := json.Marshal(resp)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, err
+		return nil, resp.StatusCode, err
 	}
-	return out, http.StatusOK, nil
+	return respBody, resp.StatusCode, nil
 }
 
-func (c *Client) postJSON(ctx context.Context, provider Provider, url string, payload any) ([]byte, int, error) {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, 0, err
-	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return nil, 0, err
+func applyAuthHeaders(httpReq *http.Request, provider Provider) {
+	switch provider.Kind {
+	case "anthropic":
+		if apiKey := strings.TrimSpace(provider.APIKey); apiKey != "" {
+			httpReq.Header.Set("x-api-key", apiKey)
+		}
+		httpReq.Header.Set("anthropic-version", "2023-06-01")
+		return
+	case "gemini":
+		// Gemini authenticates via the "?key=" query parameter appended
+		// in geminiURL, not a header.
+		return
+	case "azure":
+		if apiKey := strings.TrimSpace(provider.APIKey); apiKey != "" {
+			httpReq.Header.Set("api-key", apiKey)
+		}
+		return
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	if token := strings.TrimSpace(provider.AccessToken); token != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+token)
 	} else if apiKey := strings.TrimSpace(provider.APIKey); apiKey != "" {
@@ -316,17 +472,20 @@ func (c *Client) postJSON(ctx context.Context, provider Provider, url string, pa
 	if strings.TrimSpace(provider.AccountID) != "" {
 		httpReq.Header.Set("chatgpt-account-id", provider.AccountID)
 	}
+}
 
-	resp, err := c.httpClient.Do(httpReq)
+// withQueryParam appends a query parameter to rawURL, used by adapters
+// (Gemini's "?key=", Azure's "?api-version=") that authenticate or version
+// requests through the query string instead of a header.
+func withQueryParam(rawURL, key, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, 0, err
+		return "", err
 	}
-	defer resp.Body.Close()
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, 0, err
-	}
-	return respBody, resp.StatusCode, nil
+	q := parsed.Query()
+	q.Set(key, value)
+	parsed.RawQuery = q.Encode()
+	return parsed.String(), nil
 }
 
 func joinURL(baseURL, suffix string) (string, error) {
@@ -0,0 +1,256 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+const (
+	dispatchBaseBackoff = 100 * time.Millisecond
+	dispatchMaxBackoff  = 2 * time.Second
+)
+
+// Candidate is one provider eligible to serve a requested model, tagged
+// with its current circuit-breaker health so callers can decide whether to
+// skip it without mutating breaker state (use Manager.Match or
+// DispatchChatCompletion for that).
+type Candidate struct {
+	Provider Provider
+	Healthy  bool
+}
+
+// Candidates returns every provider that would accept requestedModel,
+// grouped into Priority tiers (lower first) and, within each tier, ordered
+// by weighted random selection among equal-priority peers — the same
+// smooth-weighted-round-robin idea nginx and service meshes use for load
+// balancing. It does not filter on health; callers that want failover
+// should use DispatchChatCompletion.
+func (m *Manager) Candidates(requestedModel string) []Candidate {
+	m.mu.RLock()
+	var matching []Provider
+	for _, p := range m.providers {
+		if ShouldProxy(p, requestedModel) {
+			matching = append(matching, p)
+		}
+	}
+	m.mu.RUnlock()
+
+	ordered := orderByPriorityAndWeight(matching)
+	health := m.healthTracker.Snapshot()
+	out := make([]Candidate, 0, len(ordered))
+	for _, p := range ordered {
+		h := health[p.Name]
+		healthy := h.State != StateOpen && h.State != StateUnauthorized
+		out = append(out, Candidate{Provider: p, Healthy: healthy})
+	}
+	return out
+}
+
+// orderByPriorityAndWeight groups providers by Priority (ascending — lower
+// numbers are tried first) and, within each tier, applies weightedShuffle
+// so heavier-weighted peers tend to be tried first without starving
+// lighter ones.
+func orderByPriorityAndWeight(providers []Provider) []Provider {
+	tiers := make(map[int][]Provider)
+	var priorities []int
+	for _, p := range providers {
+		if _, ok := tiers[p.Priority]; !ok {
+			priorities = append(priorities, p.Priority)
+		}
+		tiers[p.Priority] = append(tiers[p.Priority], p)
+	}
+	sort.Ints(priorities)
+
+	out := make([]Provider, 0, len(providers))
+	for _, priority := range priorities {
+		out = append(out, weightedShuffle(tiers[priority])...)
+	}
+	return out
+}
+
+// weightedShuffle repeatedly draws from the remaining pool with probability
+// proportional to Weight, producing an order where heavier-weighted
+// providers tend to sort earlier without being guaranteed to — the
+// randomized counterpart to nginx's smooth weighted round-robin.
+func weightedShuffle(providers []Provider) []Provider {
+	pool := append([]Provider(nil), providers...)
+	out := make([]Provider, 0, len(pool))
+	for len(pool) > 0 {
+		total := 0
+		for _, p := range pool {
+			total += p.Weight
+		}
+		pick := 0
+		if total > 0 {
+			r := rand.Intn(total)
+			running := 0
+			for i, p := range pool {
+				running += p.Weight
+				if r < running {
+					pick = i
+					break
+				}
+			}
+		}
+		out = append(out, pool[pick])
+		pool = append(pool[:pick], pool[pick+1:]...)
+	}
+	return out
+}
+
+// DispatchChatCompletion walks the candidate providers for req.Model in
+// order, skipping any the circuit breaker currently considers unavailable,
+// and fails over to the next candidate on a retryable error (5xx, 429,
+// timeout, or network failure) after an exponential backoff with jitter.
+// It returns ok=false when no provider matches req.Model at all, so the
+// caller can fall back to the mock rule engine.
+func (m *Manager) DispatchChatCompletion(ctx context.Context, client *Client, req chat.CompletionRequest) (body []byte, status int, ok bool, providerName string, err error) {
+	candidates := m.Candidates(req.Model)
+	if len(candidates) == 0 {
+		return nil, 0, false, "", nil
+	}
+
+	var lastErr error
+	var lastStatus int
+	attempted := false
+	for i, c := range candidates {
+		p := c.Provider
+		if !m.healthTracker.Available(p.Name) {
+			continue
+		}
+		attempted = true
+		model := NormalizeModel(p, req.Model)
+		start := time.Now()
+		respBody, respStatus, reqErr := client.ChatCompletions(ctx, p, req, model)
+		latency := time.Since(start)
+
+		if reqErr == nil && respStatus < 400 {
+			m.healthTracker.RecordSuccess(p.Name, latency)
+			return respBody, respStatus, true, p.Name, nil
+		}
+
+		class := classifyError(respStatus, reqErr)
+		if class == "unauthorized" {
+			m.healthTracker.RecordUnauthorized(p.Name)
+		} else {
+			m.healthTracker.RecordFailure(p.Name, class, latency)
+		}
+		lastErr, lastStatus = reqErr, respStatus
+		if !isRetryable(respStatus, reqErr) {
+			return respBody, respStatus, true, p.Name, reqErr
+		}
+		if i < len(candidates)-1 {
+			if !sleepBackoff(ctx, i) {
+				break
+			}
+		}
+	}
+
+	if !attempted {
+		return nil, http.StatusServiceUnavailable, true, "", fmt.Errorf("no healthy provider available for model %q", req.Model)
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("remote returned status %d", lastStatus)
+	}
+	return nil, lastStatus, true, "", lastErr
+}
+
+// DispatchStreamChatCompletion is DispatchChatCompletion's streaming
+// counterpart: it walks the same candidate list, skipping unhealthy
+// providers and failing over to the next candidate on a retryable error
+// after a backoff, recording every outcome into the health tracker exactly
+// like the non-streaming path. The one difference streaming forces: once a
+// candidate has handed even one delta to onDelta, the client has already
+// started receiving a response body, so the attempt can no longer be taken
+// back — a failure after that point is returned as terminal regardless of
+// whether it would otherwise have been retryable.
+// It returns ok=false when no provider matches req.Model at all, so the
+// caller can fall back to the mock rule engine.
+func (m *Manager) DispatchStreamChatCompletion(ctx context.Context, client *Client, req chat.CompletionRequest, onDelta func(delta chat.ChunkDelta) error) (status int, ok bool, providerName string, err error) {
+	candidates := m.Candidates(req.Model)
+	if len(candidates) == 0 {
+		return 0, false, "", nil
+	}
+
+	var lastErr error
+	var lastStatus int
+	attempted := false
+	for i, c := range candidates {
+		p := c.Provider
+		if !m.healthTracker.Available(p.Name) {
+			continue
+		}
+		attempted = true
+		model := NormalizeModel(p, req.Model)
+		start := time.Now()
+		emitted := false
+		respStatus, reqErr := client.StreamChatCompletions(ctx, p, req, model, func(delta chat.ChunkDelta) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		latency := time.Since(start)
+
+		if reqErr == nil {
+			m.healthTracker.RecordSuccess(p.Name, latency)
+			return respStatus, true, p.Name, nil
+		}
+
+		class := classifyError(respStatus, reqErr)
+		if class == "unauthorized" {
+			m.healthTracker.RecordUnauthorized(p.Name)
+		} else {
+			m.healthTracker.RecordFailure(p.Name, class, latency)
+		}
+		lastErr, lastStatus = reqErr, respStatus
+		if emitted || !isRetryable(respStatus, reqErr) {
+			return respStatus, true, p.Name, reqErr
+		}
+		if i < len(candidates)-1 {
+			if !sleepBackoff(ctx, i) {
+				break
+			}
+		}
+	}
+
+	if !attempted {
+		return http.StatusServiceUnavailable, true, "", fmt.Errorf("no healthy provider available for model %q", req.Model)
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("remote stream returned status %d", lastStatus)
+	}
+	return lastStatus, true, "", lastErr
+}
+
+// sleepBackoff waits an exponential-with-jitter delay before the next
+// failover attempt, returning false if the context is canceled first.
+func sleepBackoff(ctx context.Context, attempt int) bool {
+	return waitFor(ctx, backoffDelay(dispatchBaseBackoff, dispatchMaxBackoff, attempt))
+}
+
+// backoffDelay computes an exponential backoff capped at max, then applies
+// up to 50% jitter so concurrent retries don't all land on the same tick.
+func backoffDelay(base, max time.Duration, attempt int) time.Duration {
+	delay := base * time.Duration(1<<uint(attempt))
+	if delay > max {
+		delay = max
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// waitFor blocks for delay, returning false if ctx is canceled first.
+func waitFor(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,210 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the circuit-breaker state for a single provider.
+type BreakerState string
+
+const (
+	StateClosed       BreakerState = "closed"
+	StateOpen         BreakerState = "open"
+	StateHalfOpen     BreakerState = "half_open"
+	StateUnauthorized BreakerState = "unauthorized"
+)
+
+const (
+	failureThreshold = 3
+	openCooldown     = 30 * time.Second
+)
+
+// Health is the point-in-time circuit-breaker snapshot for one provider,
+// returned over the wire via GET /v1/providers/health.
+type Health struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastErrorClass      string       `json:"last_error_class,omitempty"`
+	LastLatencyMS       int64        `json:"last_latency_ms"`
+	LastCheckedAt       time.Time    `json:"last_checked_at"`
+	OpenedAt            time.Time    `json:"opened_at,omitempty"`
+}
+
+// HealthTracker records success/failure outcomes per provider name and
+// drives a simple circuit breaker: it opens after failureThreshold
+// consecutive failures, allows a single half-open probe after
+// openCooldown, and closes again on that probe's success. A provider that
+// fails with an auth error (401/403) is pinned to StateUnauthorized until
+// an operator clears it by re-upserting the provider, so a bad key can't
+// keep burning retries.
+type HealthTracker struct {
+	mu      sync.Mutex
+	health  map[string]*Health
+	configs map[string]breakerConfig
+}
+
+// breakerConfig is the per-provider failure threshold and open-state
+// cooldown, set via Configure whenever a provider is upserted so its
+// RetryPolicy governs its own breaker instead of the package defaults.
+type breakerConfig struct {
+	threshold int
+	cooldown  time.Duration
+}
+
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{health: make(map[string]*Health), configs: make(map[string]breakerConfig)}
+}
+
+// Configure sets the failure threshold and open-state cooldown used for
+// name's breaker. A non-positive value falls back to the package default.
+func (t *HealthTracker) Configure(name string, threshold int, cooldown time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if threshold <= 0 {
+		threshold = failureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = openCooldown
+	}
+	t.configs[name] = breakerConfig{threshold: threshold, cooldown: cooldown}
+}
+
+func (t *HealthTracker) configFor(name string) breakerConfig {
+	if c, ok := t.configs[name]; ok {
+		return c
+	}
+	return breakerConfig{threshold: failureThreshold, cooldown: openCooldown}
+}
+
+func (t *HealthTracker) entry(name string) *Health {
+	h, ok := t.health[name]
+	if !ok {
+		h = &Health{State: StateClosed}
+		t.health[name] = h
+	}
+	return h
+}
+
+// Available reports whether a request may currently be dispatched to the
+// named provider. A half-open probe is permitted exactly once per cooldown
+// window by transitioning the state here, before the caller attempts it.
+func (t *HealthTracker) Available(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.entry(name)
+	switch h.State {
+	case StateUnauthorized:
+		return false
+	case StateOpen:
+		if time.Since(h.OpenedAt) < t.configFor(name).cooldown {
+			return false
+		}
+		h.State = StateHalfOpen
+		return true
+	case StateHalfOpen:
+		// The single probe admitted by the StateOpen branch above is still
+		// unresolved (RecordSuccess/RecordFailure hasn't run yet); every
+		// other concurrent caller is turned away until it does.
+		return false
+	default:
+		return true
+	}
+}
+
+func (t *HealthTracker) RecordSuccess(name string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.entry(name)
+	h.State = StateClosed
+	h.ConsecutiveFailures = 0
+	h.LastErrorClass = ""
+	h.LastLatencyMS = latency.Milliseconds()
+	h.LastCheckedAt = time.Now()
+	h.OpenedAt = time.Time{}
+}
+
+func (t *HealthTracker) RecordFailure(name, errClass string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.entry(name)
+	h.ConsecutiveFailures++
+	h.LastErrorClass = errClass
+	h.LastLatencyMS = latency.Milliseconds()
+	h.LastCheckedAt = time.Now()
+	if h.State == StateHalfOpen || h.ConsecutiveFailures >= t.configFor(name).threshold {
+		h.State = StateOpen
+		h.OpenedAt = time.Now()
+	}
+}
+
+// RecordUnauthorized short-circuits the breaker: no further attempts are
+// made against this provider until it is explicitly cleared via Reset.
+func (t *HealthTracker) RecordUnauthorized(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.entry(name)
+	h.State = StateUnauthorized
+	h.LastErrorClass = "unauthorized"
+	h.LastCheckedAt = time.Now()
+}
+
+// Reset clears a provider's breaker state, as happens whenever it is
+// re-upserted through PUT /v1/providers.
+func (t *HealthTracker) Reset(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.health, name)
+}
+
+func (t *HealthTracker) Snapshot() map[string]Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]Health, len(t.health))
+	for name, h := range t.health {
+		out[name] = *h
+	}
+	return out
+}
+
+// classifyError buckets a dispatch outcome into the error classes tracked
+// by the breaker: auth failures, rate limiting, server errors, and
+// timeouts/network failures.
+func classifyError(status int, err error) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "unauthorized"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status >= 500:
+		return "server_error"
+	case err != nil:
+		if isTimeout(err) {
+			return "timeout"
+		}
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// isRetryable reports whether a dispatch outcome should be retried against
+// the next candidate provider.
+func isRetryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,38 @@
+package remote
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHealthTrackerHalfOpenAdmitsOnlyOneProbe guards against a regression
+// where every caller racing Available() after the cooldown elapsed was
+// admitted, instead of exactly one half-open probe per cooldown window.
+func TestHealthTrackerHalfOpenAdmitsOnlyOneProbe(t *testing.T) {
+	tracker := NewHealthTracker()
+	tracker.Configure("flaky", 1, time.Millisecond)
+	tracker.RecordFailure("flaky", "server_error", 0)
+	time.Sleep(2 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	admitted := make([]bool, 20)
+	for i := range admitted {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			admitted[i] = tracker.Available("flaky")
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	for _, ok := range admitted {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected exactly 1 admitted half-open probe, got %d", count)
+	}
+}
@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"chatmock/internal/chat"
+)
+
+// StreamChatCompletions opens an upstream streaming connection for the
+// given provider and invokes onDelta for every incremental piece of
+// assistant content it observes — text and, where the provider's wire
+// format carries them, tool calls — translating each provider's native
+// framing into the unified chat.ChunkDelta shape. It returns once the
+// upstream stream ends, the caller context is canceled, or an error
+// occurs.
+func (c *Client) StreamChatCompletions(ctx context.Context, provider Provider, req chat.CompletionRequest, model string, onDelta func(delta chat.ChunkDelta) error) (int, error) {
+	return adapterFor(provider.Kind).StreamChatCompletions(ctx, c, provider, req, model, onDelta)
+}
+
+// toolCallAccumulator reassembles a genuinely fragmented OpenAI/Azure
+// streaming tool-call delta, where only the first fragment at a given index
+// carries id/type/function.name and every later fragment at that index
+// carries only a partial function.arguments string to append. chatmock's own
+// mock-rule and ChatGPT-adapter tool calls are never fragmented this way, so
+// only the OpenAI- and Azure-compatible adapters need one of these.
+type toolCallAccumulator struct {
+	order []int
+	calls map[int]*chat.ToolCall
+}
+
+func newToolCallAccumulator() *toolCallAccumulator {
+	return &toolCallAccumulator{calls: make(map[int]*chat.ToolCall)}
+}
+
+// add merges a chunk's tool-call fragments into the accumulator, keyed by
+// each fragment's Index.
+func (a *toolCallAccumulator) add(fragments []chat.ToolCall) {
+	for _, f := range fragments {
+		call, ok := a.calls[f.Index]
+		if !ok {
+			call = &chat.ToolCall{Index: f.Index}
+			a.calls[f.Index] = call
+			a.order = append(a.order, f.Index)
+		}
+		if f.ID != "" {
+			call.ID = f.ID
+		}
+		if f.Type != "" {
+			call.Type = f.Type
+		}
+		if f.Function.Name != "" {
+			call.Function.Name = f.Function.Name
+		}
+		call.Function.Arguments += f.Function.Arguments
+	}
+}
+
+// flush hands every accumulated tool call to onDelta, in the order each was
+// first seen, then resets the accumulator so a later finish_reason (or a
+// provider that emits more than one) doesn't re-send the same calls.
+func (a *toolCallAccumulator) flush(onDelta func(delta chat.ChunkDelta) error) error {
+	if len(a.order) == 0 {
+		return nil
+	}
+	calls := make([]chat.ToolCall, 0, len(a.order))
+	for _, idx := range a.order {
+		calls = append(calls, *a.calls[idx])
+	}
+	a.order = nil
+	a.calls = make(map[int]*chat.ToolCall)
+	return onDelta(chat.ChunkDelta{ToolCalls: calls})
+}
+
+// streamSSE performs the POST, then scans the body for "data: " lines,
+// handing each decoded payload to handle until it signals completion.
+func (c *Client) streamSSE(ctx context.Context, provider Provider, url string, payload any, handle func(data []byte) (done bool, err error)) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	applyAuthHeaders(httpReq, provider)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		done, err := handle(data)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+		if done {
+			break
+		}
+	}
+	return resp.StatusCode, scanner.Err()
+}
@@ -0,0 +1,371 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exprNode is a compiled node of the small, safe boolean expression
+// language used by Rule.Match.When. It is intentionally far more limited
+// than a general-purpose scripting language: it can only read values out
+// of the evaluation context and combine them with ==, !=, &&, ||, !, and
+// the contains(...) helper, so a rule file can never execute arbitrary
+// code.
+type exprNode interface {
+	eval(ctx map[string]any) (any, error)
+}
+
+// parseExpr compiles a When expression once, at Set time, so that every
+// matched request only pays for evaluation, not parsing.
+func parseExpr(src string) (exprNode, error) {
+	p := &exprParser{tokens: tokenize(src)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+type token struct {
+	kind string // "ident", "string", "number", "op", "lparen", "rparen", "comma"
+	text string
+}
+
+func tokenize(src string) []token {
+	var tokens []token
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{"lparen", "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{"rparen", ")"})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{"comma", ","})
+			i++
+		case c == '"' || c == '\'':
+			quote := c
+			j := i + 1
+			var sb strings.Builder
+			for j < len(r) && r[j] != quote {
+				sb.WriteRune(r[j])
+				j++
+			}
+			tokens = append(tokens, token{"string", sb.String()})
+			i = j + 1
+		case strings.HasPrefix(string(r[i:]), "=="):
+			tokens = append(tokens, token{"op", "=="})
+			i += 2
+		case strings.HasPrefix(string(r[i:]), "!="):
+			tokens = append(tokens, token{"op", "!="})
+			i += 2
+		case strings.HasPrefix(string(r[i:]), "&&"):
+			tokens = append(tokens, token{"op", "&&"})
+			i += 2
+		case strings.HasPrefix(string(r[i:]), "||"):
+			tokens = append(tokens, token{"op", "||"})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, token{"op", "!"})
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(r) && (r[j] >= '0' && r[j] <= '9' || r[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{"number", string(r[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(r) && (isIdentRune(r[j])) {
+				j++
+			}
+			if j == i {
+				i++ // skip unrecognized rune rather than looping forever
+				continue
+			}
+			tokens = append(tokens, token{"ident", string(r[i:j])})
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *exprParser) next() (token, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != "op" || t.text != "||" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binOp{"||", left, right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != "op" || t.text != "&&" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binOp{"&&", left, right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if t, ok := p.peek(); ok && t.kind == "op" && t.text == "!" {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notOp{inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok && t.kind == "op" && (t.text == "==" || t.text == "!=") {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return binOp{t.text, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	switch t.kind {
+	case "lparen":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.next()
+		if !ok || closing.kind != "rparen" {
+			return nil, fmt.Errorf("expected closing parenthesis")
+		}
+		return inner, nil
+	case "string":
+		return literal{t.text}, nil
+	case "number":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return literal{f}, nil
+	case "ident":
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		}
+		if next, ok := p.peek(); ok && next.kind == "lparen" {
+			p.next()
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return call{t.text, args}, nil
+		}
+		return ident{t.text}, nil
+	default:
+		return nil, fmt.Errorf("unexpected token %q", t.text)
+	}
+}
+
+func (p *exprParser) parseArgs() ([]exprNode, error) {
+	var args []exprNode
+	if t, ok := p.peek(); ok && t.kind == "rparen" {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		t, ok := p.next()
+		if !ok {
+			return nil, fmt.Errorf("unterminated argument list")
+		}
+		if t.kind == "rparen" {
+			return args, nil
+		}
+		if t.kind != "comma" {
+			return nil, fmt.Errorf("expected comma, got %q", t.text)
+		}
+	}
+}
+
+type literal struct{ value any }
+
+func (l literal) eval(map[string]any) (any, error) { return l.value, nil }
+
+type ident struct{ name string }
+
+func (n ident) eval(ctx map[string]any) (any, error) {
+	v, ok := ctx[n.name]
+	if !ok {
+		return "", nil
+	}
+	return v, nil
+}
+
+type binOp struct {
+	op          string
+	left, right exprNode
+}
+
+func (b binOp) eval(ctx map[string]any) (any, error) {
+	switch b.op {
+	case "&&":
+		l, err := evalBool(b.left, ctx)
+		if err != nil || !l {
+			return false, err
+		}
+		return evalBool(b.right, ctx)
+	case "||":
+		l, err := evalBool(b.left, ctx)
+		if err != nil {
+			return false, err
+		}
+		if l {
+			return true, nil
+		}
+		return evalBool(b.right, ctx)
+	case "==", "!=":
+		lv, err := b.left.eval(ctx)
+		if err != nil {
+			return false, err
+		}
+		rv, err := b.right.eval(ctx)
+		if err != nil {
+			return false, err
+		}
+		eq := fmt.Sprintf("%v", lv) == fmt.Sprintf("%v", rv)
+		if b.op == "!=" {
+			return !eq, nil
+		}
+		return eq, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", b.op)
+	}
+}
+
+type notOp struct{ inner exprNode }
+
+func (n notOp) eval(ctx map[string]any) (any, error) {
+	v, err := evalBool(n.inner, ctx)
+	if err != nil {
+		return false, err
+	}
+	return !v, nil
+}
+
+type call struct {
+	name string
+	args []exprNode
+}
+
+func (c call) eval(ctx map[string]any) (any, error) {
+	switch c.name {
+	case "contains":
+		if len(c.args) != 2 {
+			return false, fmt.Errorf("contains() takes 2 arguments")
+		}
+		haystack, err := evalString(c.args[0], ctx)
+		if err != nil {
+			return false, err
+		}
+		needle, err := evalString(c.args[1], ctx)
+		if err != nil {
+			return false, err
+		}
+		return strings.Contains(strings.ToLower(haystack), strings.ToLower(needle)), nil
+	default:
+		return false, fmt.Errorf("unknown function %q", c.name)
+	}
+}
+
+func evalBool(n exprNode, ctx map[string]any) (bool, error) {
+	v, err := n.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression did not evaluate to a boolean")
+	}
+	return b, nil
+}
+
+func evalString(n exprNode, ctx map[string]any) (string, error) {
+	v, err := n.eval(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", v), nil
+}
@@ -1,42 +1,354 @@
 package rules
 
 import (
+	"encoding/json"
+	"errors"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+
+	"chatmock/internal/storage"
 )
 
+// rulesStorageKey is the Store key the Store persists its rules under
+// when AttachStorage is used.
+const rulesStorageKey = "rules"
+
+// Message is a minimal, rules-package-local mirror of chat.Message so this
+// package has no dependency on internal/chat.
+type Message struct {
+	Role    string
+	Content string
+}
+
+// ToolCall lets a Rule's Response drive a mocked tool-use turn.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function ToolCallFunc `json:"function"`
+}
+
+type ToolCallFunc struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Match describes the conditions under which a Rule fires. Every set field
+// must be satisfied for the rule to match; an empty Match never matches.
+type Match struct {
+	Contains string `json:"contains,omitempty"`
+	Equals   string `json:"equals,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Glob     string `json:"glob,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Model    string `json:"model,omitempty"`
+	JSONPath string `json:"json_path,omitempty"`
+	When     string `json:"when,omitempty"`
+}
+
+// Response describes what a matched Rule replies with.
+type Response struct {
+	Text       string     `json:"text,omitempty"`
+	Template   string     `json:"template,omitempty"`
+	Stream     []string   `json:"stream,omitempty"`
+	Delay      int        `json:"delay_ms,omitempty"`
+	StatusCode int        `json:"status_code,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Rule is a single mock-reply rule. Priority breaks ties between multiple
+// matching rules: higher priority is evaluated first.
 type Rule struct {
-	Contains string `json:"contains"`
-	Reply    string `json:"reply"`
+	Priority int      `json:"priority,omitempty"`
+	Match    Match    `json:"match"`
+	Response Response `json:"response"`
+
+	compiledRegex *regexp.Regexp
+	compiledWhen  exprNode
+}
+
+// legacyRule is the original flat rule shape ({"contains":"x","reply":"y"}).
+// Rule.UnmarshalJSON accepts either shape so existing /v1/rules clients
+// keep working.
+type legacyRule struct {
+	Contains      string `json:"contains"`
+	Reply         string `json:"reply"`
+	StreamDelayMS int    `json:"stream_delay_ms"`
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type alias Rule
+	var aux struct {
+		alias
+		legacyRule
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*r = Rule(aux.alias)
+	if strings.TrimSpace(aux.Contains) != "" && strings.TrimSpace(r.Match.Contains) == "" {
+		r.Match.Contains = aux.Contains
+	}
+	if strings.TrimSpace(aux.Reply) != "" && strings.TrimSpace(r.Response.Text) == "" {
+		r.Response.Text = aux.Reply
+	}
+	if aux.StreamDelayMS != 0 && r.Response.Delay == 0 {
+		r.Response.Delay = aux.StreamDelayMS
+	}
+	return nil
+}
+
+// compile fills in the unexported, pre-parsed matcher state for a rule so
+// that Match doesn't recompile a regex or re-parse a When expression on
+// every request.
+func compile(r Rule) Rule {
+	if strings.TrimSpace(r.Match.Regex) != "" {
+		if re, err := regexp.Compile(r.Match.Regex); err == nil {
+			r.compiledRegex = re
+		}
+	}
+	if strings.TrimSpace(r.Match.When) != "" {
+		if node, err := parseExpr(r.Match.When); err == nil {
+			r.compiledWhen = node
+		}
+	}
+	return r
+}
+
+// MatchContext carries everything a Rule's Match conditions can inspect.
+type MatchContext struct {
+	Model    string
+	Messages []Message
+	Body     map[string]any
+}
+
+// selected returns the message content a rule's Match should inspect: the
+// last message from the given role. When role is empty it looks for the
+// last user message, falling back to the last message overall if there is
+// no user message at all.
+func (c MatchContext) selected(role string) (Message, bool) {
+	defaultingToUser := role == ""
+	if defaultingToUser {
+		role = "user"
+	}
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if strings.EqualFold(c.Messages[i].Role, role) {
+			return c.Messages[i], true
+		}
+	}
+	if defaultingToUser && len(c.Messages) > 0 {
+		return c.Messages[len(c.Messages)-1], true
+	}
+	return Message{}, false
 }
 
 type Store struct {
-	mu    sync.RWMutex
-	rules []Rule
+	mu      sync.RWMutex
+	rules   []Rule
+	storage storage.Store
 }
 
 func NewStore(seed []Rule) *Store {
-	copySeed := append([]Rule(nil), seed...)
-	return &Store{rules: copySeed}
+	compiled := make([]Rule, 0, len(seed))
+	for _, r := range seed {
+		compiled = append(compiled, compile(r))
+	}
+	sortByPriority(compiled)
+	return &Store{rules: compiled}
+}
+
+// AttachStorage loads any rules previously persisted in store into s, then
+// persists every subsequent Set to store so rules survive a restart. Call
+// it once, right after NewStore.
+func (s *Store) AttachStorage(store storage.Store) error {
+	s.mu.Lock()
+	s.storage = store
+	s.mu.Unlock()
+	return s.ReloadFromStorage()
 }
 
+// ReloadFromStorage re-reads rules from the store attached via
+// AttachStorage and replaces the store's current rules with them. It is a
+// no-op if no storage is attached or nothing has been persisted yet;
+// callers use it to pick up out-of-band edits (see storage.Watcher).
+func (s *Store) ReloadFromStorage() error {
+	s.mu.RLock()
+	store := s.storage
+	s.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	var items []Rule
+	if err := storage.LoadJSON(store, rulesStorageKey, &items); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	s.set(items, false)
+	return nil
+}
+
+// Match reports the Response.Text of the first rule matching content,
+// treating it as the last user message. It is kept for callers that only
+// care about the plain-text reply; MatchRule exposes the full Rule.
 func (s *Store) Match(content string) (string, bool) {
+	rule, ok := s.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: content}}})
+	if !ok {
+		return "", false
+	}
+	return rule.Response.Text, true
+}
+
+// MatchRule evaluates every rule, in descending priority order, against
+// ctx and returns the first one whose Match conditions are all satisfied.
+func (s *Store) MatchRule(ctx MatchContext) (Rule, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	lowered := strings.ToLower(content)
 	for _, rule := range s.rules {
-		if strings.Contains(lowered, strings.ToLower(rule.Contains)) {
-			return rule.Reply, true
+		if ruleMatches(rule, ctx) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// CaptureGroups returns the regex submatches (index 0 is the full match,
+// followed by each capture group) from the message content r.Match.Regex
+// inspects, or nil if the rule has no regex pattern or it didn't match
+// ctx. Callers use this to expose capture groups to a Response.Template.
+func (r Rule) CaptureGroups(ctx MatchContext) []string {
+	if r.compiledRegex == nil {
+		return nil
+	}
+	msg, ok := ctx.selected(r.Match.Role)
+	if !ok {
+		return nil
+	}
+	return r.compiledRegex.FindStringSubmatch(msg.Content)
+}
+
+func ruleMatches(rule Rule, ctx MatchContext) bool {
+	m := rule.Match
+	msg, hasMsg := ctx.selected(m.Role)
+
+	if strings.TrimSpace(m.Contains) != "" {
+		if !hasMsg || !strings.Contains(strings.ToLower(msg.Content), strings.ToLower(m.Contains)) {
+			return false
+		}
+	}
+	if strings.TrimSpace(m.Equals) != "" {
+		if !hasMsg || !strings.EqualFold(msg.Content, m.Equals) {
+			return false
+		}
+	}
+	if strings.TrimSpace(m.Prefix) != "" {
+		if !hasMsg || !strings.HasPrefix(strings.ToLower(msg.Content), strings.ToLower(m.Prefix)) {
+			return false
+		}
+	}
+	if strings.TrimSpace(m.Glob) != "" {
+		matched, err := path.Match(m.Glob, msg.Content)
+		if !hasMsg || err != nil || !matched {
+			return false
 		}
 	}
-	return "", false
+	if rule.compiledRegex != nil {
+		if !hasMsg || !rule.compiledRegex.MatchString(msg.Content) {
+			return false
+		}
+	}
+	if strings.TrimSpace(m.Model) != "" {
+		if !strings.EqualFold(ctx.Model, m.Model) {
+			return false
+		}
+	}
+	if strings.TrimSpace(m.JSONPath) != "" {
+		if _, ok := lookupJSONPath(ctx.Body, m.JSONPath); !ok {
+			return false
+		}
+	}
+	if rule.compiledWhen != nil {
+		exprCtx := map[string]any{
+			"model":   ctx.Model,
+			"content": msg.Content,
+			"role":    msg.Role,
+		}
+		ok, err := evalBool(rule.compiledWhen, exprCtx)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	// A rule with no conditions at all never matches; it would otherwise
+	// swallow every request.
+	return strings.TrimSpace(m.Contains) != "" || strings.TrimSpace(m.Equals) != "" ||
+		strings.TrimSpace(m.Prefix) != "" || strings.TrimSpace(m.Glob) != "" ||
+		rule.compiledRegex != nil || strings.TrimSpace(m.Model) != "" ||
+		strings.TrimSpace(m.JSONPath) != "" || rule.compiledWhen != nil
+}
+
+// lookupJSONPath resolves a dotted path like "messages.0.role" or
+// "messages.-1.content" against a parsed JSON document.
+func lookupJSONPath(body map[string]any, path string) (any, bool) {
+	if body == nil {
+		return nil, false
+	}
+	var cur any = body
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if segment == "" {
+			continue
+		}
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, false
+			}
+			if idx < 0 {
+				idx += len(node)
+			}
+			if idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
 }
 
 func (s *Store) Set(items []Rule) {
+	s.set(items, true)
+}
+
+// set replaces the store's rules, optionally persisting the result;
+// shouldPersist is false when called from ReloadFromStorage so a reload doesn't
+// immediately re-save the document it just loaded.
+func (s *Store) set(items []Rule, shouldPersist bool) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.rules = append([]Rule(nil), items...)
+	compiled := make([]Rule, 0, len(items))
+	for _, r := range items {
+		compiled = append(compiled, compile(r))
+	}
+	sortByPriority(compiled)
+	s.rules = compiled
+	store := s.storage
+	s.mu.Unlock()
+	if shouldPersist && store != nil {
+		_ = storage.SaveJSON(store, rulesStorageKey, compiled)
+	}
 }
 
 func (s *Store) All() []Rule {
@@ -44,3 +356,9 @@ func (s *Store) All() []Rule {
 	defer s.mu.RUnlock()
 	return append([]Rule(nil), s.rules...)
 }
+
+func sortByPriority(rules []Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+}
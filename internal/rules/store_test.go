@@ -0,0 +1,141 @@
+package rules
+
+import "testing"
+
+func TestMatchRuleRegex(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{Regex: `\bticket #(\d+)\b`}, Response: Response{Text: "looking into it"}},
+	})
+
+	rule, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "please check ticket #482"}}})
+	if !ok {
+		t.Fatalf("expected regex rule to match")
+	}
+	if rule.Response.Text != "looking into it" {
+		t.Fatalf("unexpected response: %+v", rule.Response)
+	}
+
+	if _, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "no ticket reference here"}}}); ok {
+		t.Fatalf("expected regex rule not to match")
+	}
+}
+
+func TestMatchRuleJSONPath(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{JSONPath: "messages.-1.role"}, Response: Response{Text: "saw a message"}},
+	})
+	body := map[string]any{"messages": []any{map[string]any{"role": "user", "content": "hi"}}}
+
+	if _, ok := store.MatchRule(MatchContext{Body: body}); !ok {
+		t.Fatalf("expected JSONPath rule to match")
+	}
+	if _, ok := store.MatchRule(MatchContext{Body: map[string]any{}}); ok {
+		t.Fatalf("expected JSONPath rule not to match an empty body")
+	}
+}
+
+func TestMatchRuleWhen(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{When: `model == "gpt-mock-1" && contains(content, "refund")`}, Response: Response{Text: "refund policy..."}},
+	})
+
+	ctx := MatchContext{Model: "gpt-mock-1", Messages: []Message{{Role: "user", Content: "I'd like a REFUND please"}}}
+	if _, ok := store.MatchRule(ctx); !ok {
+		t.Fatalf("expected when-expression rule to match")
+	}
+
+	ctx.Model = "other-model"
+	if _, ok := store.MatchRule(ctx); ok {
+		t.Fatalf("expected when-expression rule not to match a different model")
+	}
+}
+
+func TestMatchRulePriorityOrder(t *testing.T) {
+	store := NewStore([]Rule{
+		{Priority: 1, Match: Match{Contains: "hello"}, Response: Response{Text: "low priority"}},
+		{Priority: 5, Match: Match{Contains: "hello"}, Response: Response{Text: "high priority"}},
+	})
+
+	rule, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "hello there"}}})
+	if !ok || rule.Response.Text != "high priority" {
+		t.Fatalf("expected higher priority rule to win, got %+v", rule)
+	}
+}
+
+func TestMatchRuleEqualsPrefixGlob(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{Equals: "ping"}, Response: Response{Text: "pong"}},
+		{Match: Match{Prefix: "order #"}, Response: Response{Text: "order lookup"}},
+		{Match: Match{Glob: "refund*requested"}, Response: Response{Text: "refund flow"}},
+	})
+
+	if _, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "ping"}}}); !ok {
+		t.Fatalf("expected equals rule to match")
+	}
+	if _, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "ping pong"}}}); ok {
+		t.Fatalf("expected equals rule not to match a longer message")
+	}
+
+	rule, ok := store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "order #482 please"}}})
+	if !ok || rule.Response.Text != "order lookup" {
+		t.Fatalf("expected prefix rule to match, got %+v", rule)
+	}
+
+	rule, ok = store.MatchRule(MatchContext{Messages: []Message{{Role: "user", Content: "refund already requested"}}})
+	if !ok || rule.Response.Text != "refund flow" {
+		t.Fatalf("expected glob rule to match, got %+v", rule)
+	}
+}
+
+func TestMatchRuleModel(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{Contains: "hello", Model: "gpt-mock-1"}, Response: Response{Text: "hi from mock"}},
+	})
+
+	if _, ok := store.MatchRule(MatchContext{Model: "gpt-mock-1", Messages: []Message{{Role: "user", Content: "hello there"}}}); !ok {
+		t.Fatalf("expected model-scoped rule to match its model")
+	}
+	if _, ok := store.MatchRule(MatchContext{Model: "other-model", Messages: []Message{{Role: "user", Content: "hello there"}}}); ok {
+		t.Fatalf("expected model-scoped rule not to match a different model")
+	}
+}
+
+func TestMatchRuleDefaultsToLastUserMessage(t *testing.T) {
+	store := NewStore([]Rule{
+		{Match: Match{Contains: "pizza"}, Response: Response{Text: "pizza time"}},
+	})
+
+	ctx := MatchContext{Messages: []Message{
+		{Role: "user", Content: "I want pizza"},
+		{Role: "assistant", Content: "sure, what toppings?"},
+		{Role: "user", Content: "actually never mind"},
+	}}
+	if _, ok := store.MatchRule(ctx); ok {
+		t.Fatalf("expected rule not to match the assistant's message or a stale user message")
+	}
+}
+
+func TestRuleCaptureGroups(t *testing.T) {
+	rule := compile(Rule{Match: Match{Regex: `\bticket #(\d+)\b`}})
+	ctx := MatchContext{Messages: []Message{{Role: "user", Content: "please check ticket #482"}}}
+
+	groups := rule.CaptureGroups(ctx)
+	if len(groups) != 2 || groups[1] != "482" {
+		t.Fatalf("expected capture group \"482\", got %+v", groups)
+	}
+
+	noMatchCtx := MatchContext{Messages: []Message{{Role: "user", Content: "no ticket here"}}}
+	if groups := rule.CaptureGroups(noMatchCtx); groups != nil {
+		t.Fatalf("expected no capture groups for non-matching content, got %+v", groups)
+	}
+}
+
+func TestRuleUnmarshalLegacyShape(t *testing.T) {
+	var rule Rule
+	if err := rule.UnmarshalJSON([]byte(`{"contains":"pizza","reply":"pizza time","stream_delay_ms":10}`)); err != nil {
+		t.Fatalf("unmarshal legacy rule: %v", err)
+	}
+	if rule.Match.Contains != "pizza" || rule.Response.Text != "pizza time" || rule.Response.Delay != 10 {
+		t.Fatalf("legacy rule not normalized: %+v", rule)
+	}
+}
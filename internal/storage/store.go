@@ -0,0 +1,90 @@
+// Package storage persists named JSON documents (providers, rules) to disk
+// so a manager's state survives a restart, and watches that disk state for
+// out-of-band edits so they can be hot-reloaded.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Store.Load when no document is saved under key.
+var ErrNotFound = errors.New("storage: not found")
+
+// Store persists named JSON documents. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Load(key string) ([]byte, error)
+	Save(key string, data []byte) error
+}
+
+// FileStore persists each key as its own JSON file under Dir, written
+// atomically (temp file + rename) so a crash mid-write can never leave a
+// half-written document behind.
+//
+// A BoltDB/sqlite-backed Store was considered so large provider/rule sets
+// could be queried rather than read whole, but both pull in a third-party
+// dependency this project otherwise avoids entirely; one JSON file per key
+// is plenty for the document sizes chatmock actually persists.
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+func (f *FileStore) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (f *FileStore) Save(key string, data []byte) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, f.path(key))
+}
+
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.Dir, key+".json")
+}
+
+// SaveJSON marshals v and saves it to store under key.
+func SaveJSON(store Store, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return store.Save(key, data)
+}
+
+// LoadJSON loads the document saved under key and unmarshals it into v. It
+// returns ErrNotFound unchanged so callers can treat "nothing persisted
+// yet" as a no-op rather than an error.
+func LoadJSON(store Store, key string, v any) error {
+	data, err := store.Load(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	if _, err := store.Load("widgets"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound before any save, got %v", err)
+	}
+
+	type widget struct {
+		Name string `json:"name"`
+	}
+	if err := SaveJSON(store, "widgets", widget{Name: "sprocket"}); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+
+	var got widget
+	if err := LoadJSON(store, "widgets", &got); err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	if got.Name != "sprocket" {
+		t.Fatalf("expected sprocket, got %q", got.Name)
+	}
+}
+
+func TestFileStoreAtomicWriteLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	if err := store.Save("widgets", []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "widgets.json" {
+		t.Fatalf("expected only widgets.json in dir, got %v", entries)
+	}
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	inner := NewFileStore(t.TempDir())
+	store := NewEncryptedStore(inner, "correct horse battery staple")
+
+	if err := store.Save("secret", []byte("top secret payload")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	plaintext, err := store.Load("secret")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(plaintext) != "top secret payload" {
+		t.Fatalf("expected round-tripped plaintext, got %q", plaintext)
+	}
+
+	ciphertext, err := inner.Load("secret")
+	if err != nil {
+		t.Fatalf("inner Load: %v", err)
+	}
+	if string(ciphertext) == "top secret payload" {
+		t.Fatal("expected ciphertext on disk to differ from plaintext")
+	}
+}
+
+func TestEncryptedStoreWrongPassphraseFails(t *testing.T) {
+	inner := NewFileStore(t.TempDir())
+	writer := NewEncryptedStore(inner, "correct horse battery staple")
+	if err := writer.Save("secret", []byte("top secret payload")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reader := NewEncryptedStore(inner, "wrong passphrase")
+	if _, err := reader.Load("secret"); err == nil {
+		t.Fatal("expected decryption with the wrong passphrase to fail")
+	}
+}
+
+func TestWatcherNotifiesOnChange(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	if err := store.Save("providers", []byte(`[]`)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	changes := make(chan string, 4)
+	watcher := NewWatcher(dir, 10*time.Millisecond, func(key string) {
+		changes <- key
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "providers.json"), []byte(`[{"name":"x"}]`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	select {
+	case key := <-changes:
+		if key != "providers" {
+			t.Fatalf("expected key 'providers', got %q", key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for watcher to notice the change")
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Watcher polls a directory of Store-managed JSON files for external edits
+// (e.g. a config-management tool overwriting providers.json by hand) and
+// invokes onChange with the affected key when one changes. It polls on a
+// fixed interval rather than using a filesystem-event API like fsnotify,
+// keeping chatmock free of third-party dependencies.
+type Watcher struct {
+	dir      string
+	interval time.Duration
+	onChange func(key string)
+
+	mu     sync.Mutex
+	mtimes map[string]time.Time
+	stop   chan struct{}
+}
+
+func NewWatcher(dir string, interval time.Duration, onChange func(key string)) *Watcher {
+	w := &Watcher{dir: dir, interval: interval, onChange: onChange, mtimes: map[string]time.Time{}}
+	w.poll(false) // seed mtimes with whatever's already on disk so Start doesn't fire a reload storm for pre-existing files
+	return w
+}
+
+// Start begins polling in a background goroutine. Call Stop to end it.
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	w.stop = make(chan struct{})
+	stop := w.stop
+	w.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.poll(true)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background polling goroutine started by Start.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+func (w *Watcher) poll(notify bool) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if ext != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		key := name[:len(name)-len(ext)]
+		prev, known := w.mtimes[key]
+		w.mtimes[key] = info.ModTime()
+		if notify && (!known || info.ModTime().After(prev)) {
+			w.onChange(key)
+		}
+	}
+}
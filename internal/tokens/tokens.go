@@ -0,0 +1,112 @@
+// Package tokens provides pluggable token counting for populating
+// prompt_tokens/completion_tokens usage, whether from a cheap built-in
+// heuristic, a loaded tiktoken-style vocabulary, or counts passed
+// through verbatim from an upstream provider.
+package tokens
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+
+	"chatmock/internal/chat"
+)
+
+// Counter estimates (or looks up) how many tokens text costs for model.
+type Counter interface {
+	Count(model, text string) int
+}
+
+// HeuristicCounter is the default Counter: a cheap ~4-characters-per-token
+// estimate, good enough to exercise a client's usage-accounting code
+// without a real tokenizer.
+type HeuristicCounter struct{}
+
+func (HeuristicCounter) Count(_ string, text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+	chars := len([]rune(text))
+	if chars < 4 {
+		return 1
+	}
+	return chars / 4
+}
+
+// PassthroughCounter returns token counts explicitly recorded via
+// Observe (e.g. an Ollama response's prompt_eval_count/eval_count),
+// keyed by the exact (model, text) pair, and falls back to Fallback for
+// anything that was never observed.
+type PassthroughCounter struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	Fallback Counter
+}
+
+func NewPassthroughCounter(fallback Counter) *PassthroughCounter {
+	if fallback == nil {
+		fallback = HeuristicCounter{}
+	}
+	return &PassthroughCounter{counts: make(map[string]int), Fallback: fallback}
+}
+
+// Observe records a token count reported by an upstream provider so a
+// later Count call for the identical (model, text) pair returns the
+// real number instead of an estimate.
+func (p *PassthroughCounter) Observe(model, text string, count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[passthroughKey(model, text)] = count
+}
+
+func (p *PassthroughCounter) Count(model, text string) int {
+	p.mu.Lock()
+	count, ok := p.counts[passthroughKey(model, text)]
+	p.mu.Unlock()
+	if ok {
+		return count
+	}
+	return p.Fallback.Count(model, text)
+}
+
+func passthroughKey(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromEnv builds the Counter chatmock should use given the process
+// environment: a TiktokenCounter backed by CHATMOCK_TIKTOKEN_DIR when
+// set, otherwise the HeuristicCounter, always wrapped in a
+// PassthroughCounter so upstream-reported counts win whenever one has
+// been observed for the exact text in question.
+func FromEnv() Counter {
+	var base Counter = HeuristicCounter{}
+	if dir := strings.TrimSpace(os.Getenv("CHATMOCK_TIKTOKEN_DIR")); dir != "" {
+		base = LoadTiktokenCounter(dir, HeuristicCounter{})
+	}
+	return NewPassthroughCounter(base)
+}
+
+// CountMessages sums counter's estimate across every message's content,
+// joined in conversation order so a single Count call sees the whole
+// exchange the way a real tokenizer would.
+func CountMessages(counter Counter, model string, messages []chat.Message) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	return counter.Count(model, JoinMessages(messages))
+}
+
+// JoinMessages renders messages as the single block of text Count sees
+// for CountMessages, so callers that need to key off the exact same
+// text (e.g. PassthroughCounter.Observe) stay consistent with it.
+func JoinMessages(messages []chat.Message) string {
+	parts := make([]string, len(messages))
+	for i, m := range messages {
+		parts[i] = m.Content
+	}
+	return strings.Join(parts, "\n")
+}
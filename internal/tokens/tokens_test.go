@@ -0,0 +1,118 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"chatmock/internal/chat"
+)
+
+func TestHeuristicCounter(t *testing.T) {
+	var c HeuristicCounter
+	if got := c.Count("gpt-mock-1", ""); got != 0 {
+		t.Fatalf("expected 0 tokens for empty text, got %d", got)
+	}
+	if got := c.Count("gpt-mock-1", "hi"); got != 1 {
+		t.Fatalf("expected a 1-token floor for short text, got %d", got)
+	}
+	if got := c.Count("gpt-mock-1", "12345678"); got != 2 {
+		t.Fatalf("expected 8 chars / 4 = 2 tokens, got %d", got)
+	}
+}
+
+func TestPassthroughCounterObserve(t *testing.T) {
+	p := NewPassthroughCounter(HeuristicCounter{})
+	if got := p.Count("ollama/llama3", "hello"); got != 1 {
+		t.Fatalf("expected fallback estimate before Observe, got %d", got)
+	}
+	p.Observe("ollama/llama3", "hello", 7)
+	if got := p.Count("ollama/llama3", "hello"); got != 7 {
+		t.Fatalf("expected observed count 7, got %d", got)
+	}
+	if got := p.Count("ollama/llama3", "goodbye"); got == 7 {
+		t.Fatalf("observed count must not leak to a different text")
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	var c HeuristicCounter
+	got := CountMessages(c, "gpt-mock-1", []chat.Message{{Role: "user", Content: "12345678"}})
+	if got != 2 {
+		t.Fatalf("expected 2 tokens, got %d", got)
+	}
+	if got := CountMessages(c, "gpt-mock-1", nil); got != 0 {
+		t.Fatalf("expected 0 tokens for no messages, got %d", got)
+	}
+}
+
+func TestTiktokenCounterFallsBackWithoutVocab(t *testing.T) {
+	counter := LoadTiktokenCounter(filepath.Join(t.TempDir(), "missing"), HeuristicCounter{})
+	if got := counter.Count("gpt-mock-1", "12345678"); got != 2 {
+		t.Fatalf("expected fallback heuristic count 2, got %d", got)
+	}
+}
+
+func TestTiktokenCounterLoadsVocab(t *testing.T) {
+	dir := t.TempDir()
+	writeVocab(t, filepath.Join(dir, "cl100k_base.tiktoken"), map[string]int{
+		"hello": 0,
+		" ":     1,
+		"world": 2,
+	})
+	counter := LoadTiktokenCounter(dir, HeuristicCounter{})
+	if got := counter.Count("gpt-4", "hello world"); got != 3 {
+		t.Fatalf("expected 3 vocab tokens, got %d", got)
+	}
+}
+
+// TestTiktokenCounterScalesWithRealisticVocab guards against the greedy
+// matcher regressing back to a linear scan of the vocabulary at every
+// text position: with a vocab sized closer to a real encoding (cl100k_base
+// has ~100k entries), a linear scan turns a large prompt into a
+// multi-second Count call, which every request pays for via
+// CountMessages and the budget middleware.
+func TestTiktokenCounterScalesWithRealisticVocab(t *testing.T) {
+	dir := t.TempDir()
+	ranks := make(map[string]int, 20000)
+	alphabet := "abcdefghijklmnopqrstuvwxyz"
+	for i := 0; i < 20000; i++ {
+		tok := strconv.Itoa(i) + string(alphabet[i%len(alphabet)])
+		ranks[tok] = i
+	}
+	ranks["hello"] = len(ranks)
+	ranks[" world"] = len(ranks)
+	writeVocab(t, filepath.Join(dir, "cl100k_base.tiktoken"), ranks)
+
+	counter := LoadTiktokenCounter(dir, HeuristicCounter{})
+	text := ""
+	for i := 0; i < 2000; i++ {
+		text += "hello world "
+	}
+
+	start := time.Now()
+	got := counter.Count("gpt-4", text)
+	elapsed := time.Since(start)
+
+	if got == 0 {
+		t.Fatal("expected a non-zero token count")
+	}
+	if elapsed > time.Second {
+		t.Fatalf("Count took %s against a 20k-entry vocab; expected a trie lookup, not a linear scan", elapsed)
+	}
+}
+
+func writeVocab(t *testing.T, path string, ranks map[string]int) {
+	t.Helper()
+	var lines []byte
+	for tok, rank := range ranks {
+		line := base64.StdEncoding.EncodeToString([]byte(tok)) + " " + strconv.Itoa(rank) + "\n"
+		lines = append(lines, []byte(line)...)
+	}
+	if err := os.WriteFile(path, lines, 0o644); err != nil {
+		t.Fatalf("write vocab: %v", err)
+	}
+}